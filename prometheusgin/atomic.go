@@ -0,0 +1,34 @@
+// prometheusgin/atomic.go
+
+package prometheusgin
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+func atomicAddFloat(bits *atomic.Uint64, v float64) {
+	for {
+		old := bits.Load()
+		if bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+type updateMarker struct {
+	updated atomic.Int64
+}
+
+func (u *updateMarker) init(now time.Time) {
+	u.updated.Store(now.UnixNano())
+}
+
+func (u *updateMarker) touch() {
+	u.updated.Store(time.Now().UnixNano())
+}
+
+func (u *updateMarker) updatedAt() time.Time {
+	return time.Unix(0, u.updated.Load())
+}
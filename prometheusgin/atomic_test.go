@@ -0,0 +1,122 @@
+package prometheusgin
+
+import (
+	"sync"
+	"testing"
+)
+
+type mutexValue struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (m *mutexValue) Add(v float64) {
+	m.mu.Lock()
+	m.value += v
+	m.mu.Unlock()
+}
+
+func (m *mutexValue) Inc() {
+	m.Add(1)
+}
+
+func benchmarkParallel(b *testing.B, op func()) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			op()
+		}
+	})
+}
+
+func BenchmarkCounterAdd(b *testing.B) {
+	b.Run("atomic", func(b *testing.B) {
+		counter := NewCounter("bench_total", "Benchmark counter.", nil)
+		benchmarkParallel(b, func() { counter.Add(0.5) })
+	})
+	b.Run("mutex", func(b *testing.B) {
+		var baseline mutexValue
+		benchmarkParallel(b, func() { baseline.Add(0.5) })
+	})
+}
+
+func BenchmarkGaugeAdd(b *testing.B) {
+	b.Run("atomic", func(b *testing.B) {
+		gauge := NewGauge("bench", "Benchmark gauge.", nil)
+		benchmarkParallel(b, func() { gauge.Add(0.5) })
+	})
+	b.Run("mutex", func(b *testing.B) {
+		var baseline mutexValue
+		benchmarkParallel(b, func() { baseline.Add(0.5) })
+	})
+}
+
+func BenchmarkGaugeInc(b *testing.B) {
+	b.Run("atomic", func(b *testing.B) {
+		gauge := NewGauge("bench", "Benchmark gauge.", nil)
+		benchmarkParallel(b, gauge.Inc)
+	})
+	b.Run("mutex", func(b *testing.B) {
+		var baseline mutexValue
+		benchmarkParallel(b, baseline.Inc)
+	})
+}
+
+func TestCounterAndGaugeConcurrentUpdates(t *testing.T) {
+	counter := NewCounter("test_total", "Test counter.", nil)
+	gauge := NewGauge("test", "Test gauge.", nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				counter.Inc()
+				counter.Add(0.5)
+				gauge.Inc()
+				gauge.Add(0.25)
+				gauge.Add(-2)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := counter.value(); got != 12000 {
+		t.Errorf("counter = %v, want 12000", got)
+	}
+	if got := gauge.value(); got != -6000 {
+		t.Errorf("gauge = %v, want -6000", got)
+	}
+	gauge.Set(3.5)
+	gauge.Dec()
+	if got := gauge.value(); got != 2.5 {
+		t.Errorf("gauge after Set and Dec = %v, want 2.5", got)
+	}
+}
+
+func TestGaugeSetIsAtomicWithAdd(t *testing.T) {
+	gauge := NewGauge("test", "Test gauge.", nil)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			gauge.Set(0)
+			gauge.Add(100)
+			gauge.Add(0.25)
+		}
+	}()
+	for i := 0; i < 100000; i++ {
+		if v := gauge.value(); v != 0 && v != 100 && v != 100.25 {
+			close(stop)
+			<-done
+			t.Fatalf("gauge = %v, a value it never held", v)
+		}
+	}
+	close(stop)
+	<-done
+}
@@ -0,0 +1,62 @@
+// prometheusgin/buckets.go
+
+package prometheusgin
+
+import (
+	"fmt"
+	"math"
+)
+
+func LinearBuckets(start, width float64, count int) []float64 {
+	if count < 1 {
+		panic(fmt.Sprintf("LinearBuckets needs a positive count, got %d", count))
+	}
+	if width <= 0 {
+		panic(fmt.Sprintf("LinearBuckets needs a positive width, got %v", width))
+	}
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start + float64(i)*width
+	}
+	return buckets
+}
+
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	if count < 1 {
+		panic(fmt.Sprintf("ExponentialBuckets needs a positive count, got %d", count))
+	}
+	if start <= 0 {
+		panic(fmt.Sprintf("ExponentialBuckets needs a positive start value, got %v", start))
+	}
+	if factor <= 1 {
+		panic(fmt.Sprintf("ExponentialBuckets needs a factor greater than 1, got %v", factor))
+	}
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+func ExponentialBucketsRange(min, max float64, count int) []float64 {
+	if count < 1 {
+		panic(fmt.Sprintf("ExponentialBucketsRange needs a positive count, got %d", count))
+	}
+	if min <= 0 {
+		panic(fmt.Sprintf("ExponentialBucketsRange needs a positive min value, got %v", min))
+	}
+	if max <= min {
+		panic(fmt.Sprintf("ExponentialBucketsRange needs a max value greater than min, got %v <= %v", max, min))
+	}
+	if count == 1 {
+		return []float64{min}
+	}
+	factor := math.Pow(max/min, 1/float64(count-1))
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = min * math.Pow(factor, float64(i))
+	}
+	buckets[count-1] = max
+	return buckets
+}
@@ -0,0 +1,96 @@
+package prometheusgin
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestBucketGenerators(t *testing.T) {
+	tests := []struct {
+		name string
+		got  []float64
+		want []float64
+	}{
+		{"linear", LinearBuckets(1, 2, 4), []float64{1, 3, 5, 7}},
+		{"linear negative start", LinearBuckets(-1, 0.5, 3), []float64{-1, -0.5, 0}},
+		{"exponential", ExponentialBuckets(1, 2, 4), []float64{1, 2, 4, 8}},
+		{"exponential range", ExponentialBucketsRange(1, 1000, 4), []float64{1, 10, 100, 1000}},
+		{"exponential range single", ExponentialBucketsRange(5, 10, 1), []float64{5}},
+	}
+	for _, tt := range tests {
+		if len(tt.got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+			continue
+		}
+		for i := range tt.want {
+			if math.Abs(tt.got[i]-tt.want[i]) > 1e-9*math.Abs(tt.want[i]) {
+				t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+				break
+			}
+		}
+		if err := validateBuckets(tt.name, tt.got, false); err != nil {
+			t.Errorf("%s: generated invalid buckets: %v", tt.name, err)
+		}
+	}
+}
+
+func TestBucketGeneratorsPanic(t *testing.T) {
+	tests := map[string]func(){
+		"linear zero count":             func() { LinearBuckets(0, 1, 0) },
+		"linear zero width":             func() { LinearBuckets(0, 0, 3) },
+		"linear negative width":         func() { LinearBuckets(0, -1, 3) },
+		"exponential zero count":        func() { ExponentialBuckets(1, 2, 0) },
+		"exponential zero start":        func() { ExponentialBuckets(0, 2, 3) },
+		"exponential factor one":        func() { ExponentialBuckets(1, 1, 3) },
+		"exponential range zero count":  func() { ExponentialBucketsRange(1, 10, 0) },
+		"exponential range zero min":    func() { ExponentialBucketsRange(0, 10, 3) },
+		"exponential range max too low": func() { ExponentialBucketsRange(10, 10, 3) },
+	}
+	for name, generate := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("did not panic")
+				}
+			}()
+			generate()
+		})
+	}
+}
+
+func TestValidateBuckets(t *testing.T) {
+	tests := []struct {
+		name    string
+		buckets []float64
+		native  bool
+		valid   bool
+	}{
+		{"increasing", []float64{1, 2, 3}, false, true},
+		{"empty", nil, false, false},
+		{"empty native", nil, true, true},
+		{"NaN", []float64{1, math.NaN(), 3}, false, false},
+		{"duplicate", []float64{1, 2, 2}, false, false},
+		{"decreasing", []float64{1, 3, 2}, false, false},
+	}
+	for _, tt := range tests {
+		if err := validateBuckets("test_seconds", tt.buckets, tt.native); (err == nil) != tt.valid {
+			t.Errorf("%s: validateBuckets(%v) = %v, want valid %v", tt.name, tt.buckets, err, tt.valid)
+		}
+	}
+}
+
+func TestNewHistogramStripsInfBucket(t *testing.T) {
+	h := NewHistogram("test_seconds", "Test histogram.", []float64{1, 2, math.Inf(1)}, nil)
+	if want := []float64{1, 2}; !reflect.DeepEqual(h.buckets, want) {
+		t.Fatalf("buckets = %v, want %v", h.buckets, want)
+	}
+	h.Observe(5)
+	sample := h.Snapshot().Samples[0]
+	if len(sample.Buckets) != 2 || sample.Count != 1 || sample.Buckets[1].CumulativeCount != 0 {
+		t.Fatalf("sample = %+v, want two finite buckets and the observation only in +Inf", sample)
+	}
+	if err := NewMetricRegistry().Register(h); err != nil {
+		t.Fatalf("Register = %v", err)
+	}
+}
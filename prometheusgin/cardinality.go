@@ -0,0 +1,57 @@
+// prometheusgin/cardinality.go
+
+package prometheusgin
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+const (
+	OverflowLabelValue = "__overflow__"
+
+	maxRejectedSets = 4096
+)
+
+type cardinalityGuard struct {
+	limit        int
+	rejected     *CounterVec
+	rejectedSets map[uint64]struct{}
+	mu           sync.Mutex
+}
+
+type cardinalityLimited interface {
+	setCardinalityGuard(guard *cardinalityGuard)
+}
+
+func (g *cardinalityGuard) allow(family, key string, current int) bool {
+	if g == nil || g.limit <= 0 || current < g.limit {
+		return true
+	}
+	h := fnv.New64a()
+	h.Write([]byte(family))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	g.mu.Lock()
+	_, seen := g.rejectedSets[sum]
+	if !seen {
+		if len(g.rejectedSets) >= maxRejectedSets {
+			g.rejectedSets = make(map[uint64]struct{})
+		}
+		g.rejectedSets[sum] = struct{}{}
+	}
+	g.mu.Unlock()
+	if !seen {
+		g.rejected.WithLabelValues(family).Inc()
+	}
+	return false
+}
+
+func overflowLabels(labels map[string]string) map[string]string {
+	overflow := make(map[string]string, len(labels))
+	for name := range labels {
+		overflow[name] = OverflowLabelValue
+	}
+	return overflow
+}
@@ -0,0 +1,70 @@
+package prometheusgin
+
+import (
+	"strconv"
+	"testing"
+)
+
+func rejectedCount(reg *MetricRegistry, family string) float64 {
+	return reg.guard.rejected.WithLabelValues(family).value()
+}
+
+func TestCardinalityGuardOverflowsVecChildren(t *testing.T) {
+	reg := NewMetricRegistry(WithMaxSeriesPerFamily(2))
+	vec := NewCounterVec("test_total", "Test counter.", []string{"user"})
+	reg.MustRegister(vec)
+
+	for _, user := range []string{"a", "b", "c", "d", "c"} {
+		vec.WithLabelValues(user).Inc()
+	}
+
+	samples := vec.Snapshot().Samples
+	want := map[string]float64{"a": 1, "b": 1, OverflowLabelValue: 3}
+	if len(samples) != len(want) {
+		t.Fatalf("got %d series, want %d: %+v", len(samples), len(want), samples)
+	}
+	for _, sample := range samples {
+		if user := sample.Labels[0].Value; sample.Value != want[user] {
+			t.Errorf("test_total{user=%q} = %v, want %v", user, sample.Value, want[user])
+		}
+	}
+	if got := rejectedCount(reg, "test_total"); got != 2 {
+		t.Errorf("prometheusgin_rejected_series_total = %v, want 2 distinct rejected label sets", got)
+	}
+}
+
+func TestCardinalityGuardRejectsRegisteredSeries(t *testing.T) {
+	reg := NewMetricRegistry(WithMaxSeriesPerFamily(2))
+	for _, user := range []string{"a", "b"} {
+		if err := reg.Register(NewCounter("test_total", "Test counter.", map[string]string{"user": user})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := reg.Register(NewCounter("test_total", "Test counter.", map[string]string{"user": "c"})); err == nil {
+			t.Fatal("registering a third series succeeded, want a limit error")
+		}
+	}
+	if got := rejectedCount(reg, "test_total"); got != 1 {
+		t.Errorf("prometheusgin_rejected_series_total = %v, want 1", got)
+	}
+}
+
+func TestCardinalityGuardMemoryIsBounded(t *testing.T) {
+	reg := NewMetricRegistry(WithMaxSeriesPerFamily(2))
+	vec := NewCounterVec("test_total", "Test counter.", []string{"user"})
+	reg.MustRegister(vec)
+
+	for i := 0; i < 100000; i++ {
+		vec.WithLabelValues(strconv.Itoa(i)).Inc()
+	}
+	if n := len(reg.guard.rejectedSets); n > maxRejectedSets {
+		t.Fatalf("guard tracks %d rejected label sets, want at most %d", n, maxRejectedSets)
+	}
+	if n := len(vec.children); n != 3 {
+		t.Fatalf("got %d children, want 2 plus the overflow series", n)
+	}
+	if got := rejectedCount(reg, "test_total"); got != 99998 {
+		t.Fatalf("prometheusgin_rejected_series_total = %v, want 99998", got)
+	}
+}
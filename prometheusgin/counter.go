@@ -1,50 +1,142 @@
 package prometheusgin
 
 import (
-	"fmt"
-	"strings"
+	"math"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Counter struct {
 	name        string
 	help        string
-	value       float64
+	valBits     atomic.Uint64
+	valInt      atomic.Uint64
 	labels      map[string]string
 	mu          sync.Mutex
 	labelString string
+	unit        string
+	created     time.Time
+	exemplar    atomic.Pointer[Exemplar]
+	updateMarker
 }
 
 func NewCounter(name, help string, labels map[string]string) *Counter {
 	labelStr := formatLabels(labels)
-	return &Counter{
+	now := time.Now()
+	c := &Counter{
 		name:        name,
 		help:        help,
 		labels:      labels,
 		labelString: labelStr,
+		created:     now,
 	}
+	c.updateMarker.init(now)
+	return c
+}
+
+func (c *Counter) SetUnit(unit string) error {
+	if err := validateUnit(c.name, TypeCounter, unit); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unit = unit
+	return nil
 }
 
 func (c *Counter) Inc() {
-	c.Add(1)
+	c.valInt.Add(1)
+	c.touch()
 }
 
 func (c *Counter) Add(v float64) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.value += v
+	if v >= 0 && v < math.MaxUint64 {
+		if ival := uint64(v); float64(ival) == v {
+			c.valInt.Add(ival)
+			c.touch()
+			return
+		}
+	}
+	atomicAddFloat(&c.valBits, v)
+	c.touch()
+}
+
+func (c *Counter) AddWithExemplar(v float64, labels map[string]string) {
+	exemplar, err := newExemplar(v, labels)
+	if err != nil {
+		panic(err)
+	}
+	c.Add(v)
+	c.exemplar.Store(exemplar)
+}
+
+func (c *Counter) value() float64 {
+	return float64(c.valInt.Load()) + math.Float64frombits(c.valBits.Load())
 }
 
 func (c *Counter) Export() string {
+	return exportText(c)
+}
+
+func (c *Counter) Snapshot() MetricSnapshot {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("# HELP %s %s\n", c.name, c.help))
-	sb.WriteString(fmt.Sprintf("# TYPE %s counter\n", c.name))
-	if c.labelString != "" {
-		sb.WriteString(fmt.Sprintf("%s{%s} %s\n", c.name, c.labelString, formatFloat(c.value)))
-	} else {
-		sb.WriteString(fmt.Sprintf("%s %s\n", c.name, formatFloat(c.value)))
-	}
-	return sb.String()
+	unit := c.unit
+	c.mu.Unlock()
+	return MetricSnapshot{
+		Name: c.name,
+		Help: c.help,
+		Type: TypeCounter,
+		Unit: unit,
+		Samples: []Sample{{
+			Labels:   labelPairs(c.labels),
+			Value:    c.value(),
+			Created:  c.created,
+			Exemplar: c.exemplar.Load(),
+		}},
+	}
+}
+
+type CounterVec struct {
+	*metricVec
+}
+
+func NewCounterVec(name, help string, labelNames []string) *CounterVec {
+	return &CounterVec{
+		metricVec: newMetricVec(name, help, TypeCounter, labelNames, func(labels map[string]string) Metric {
+			return NewCounter(name, help, labels)
+		}),
+	}
+}
+
+func (v *CounterVec) GetMetricWithLabelValues(values ...string) (*Counter, error) {
+	metric, err := v.getMetricWithLabelValues(values)
+	if err != nil {
+		return nil, err
+	}
+	return metric.(*Counter), nil
+}
+
+func (v *CounterVec) GetMetricWith(labels map[string]string) (*Counter, error) {
+	metric, err := v.getMetricWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return metric.(*Counter), nil
+}
+
+func (v *CounterVec) WithLabelValues(values ...string) *Counter {
+	counter, err := v.GetMetricWithLabelValues(values...)
+	if err != nil {
+		panic(err)
+	}
+	return counter
+}
+
+func (v *CounterVec) With(labels map[string]string) *Counter {
+	counter, err := v.GetMetricWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return counter
 }
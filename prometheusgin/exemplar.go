@@ -0,0 +1,69 @@
+// prometheusgin/exemplar.go
+
+package prometheusgin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ExemplarTraceIDLabel = "trace_id"
+	TraceIDContextKey    = "trace_id"
+
+	maxExemplarLabelRunes = 128
+)
+
+func newExemplar(value float64, labels map[string]string) (*Exemplar, error) {
+	runes := 0
+	for name, v := range labels {
+		if !labelNameRE.MatchString(name) {
+			return nil, fmt.Errorf("exemplar label name %q is invalid", name)
+		}
+		runes += utf8.RuneCountInString(name) + utf8.RuneCountInString(v)
+	}
+	if runes > maxExemplarLabelRunes {
+		return nil, fmt.Errorf("exemplar labels have %d runes, exceeding the limit of %d", runes, maxExemplarLabelRunes)
+	}
+	return &Exemplar{Labels: labelPairs(labels), Value: value, Timestamp: time.Now()}, nil
+}
+
+type traceIDContextKey struct{}
+
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDContextKey{}, traceID)
+}
+
+func TraceIDFromContext(c *gin.Context) string {
+	if traceID := c.GetString(TraceIDContextKey); traceID != "" {
+		return traceID
+	}
+	if traceID, ok := c.Request.Context().Value(traceIDContextKey{}).(string); ok && traceID != "" {
+		return traceID
+	}
+	return traceIDFromTraceparent(c.GetHeader("traceparent"))
+}
+
+func traceIDFromTraceparent(header string) string {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 {
+		return ""
+	}
+	traceID := strings.ToLower(parts[1])
+	if strings.Trim(traceID, "0123456789abcdef") != "" || strings.Trim(traceID, "0") == "" {
+		return ""
+	}
+	return traceID
+}
+
+func exemplarLabels(traceID string) map[string]string {
+	if traceID == "" || utf8.RuneCountInString(ExemplarTraceIDLabel)+utf8.RuneCountInString(traceID) > maxExemplarLabelRunes {
+		return nil
+	}
+	return map[string]string{ExemplarTraceIDLabel: traceID}
+}
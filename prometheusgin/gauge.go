@@ -1,64 +1,127 @@
 package prometheusgin
 
 import (
-	"fmt"
-	"strings"
+	"math"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Gauge struct {
 	name        string
 	help        string
-	value       float64
+	valBits     atomic.Uint64
 	labels      map[string]string
 	mu          sync.Mutex
 	labelString string
+	unit        string
+	updateMarker
 }
 
 func NewGauge(name, help string, labels map[string]string) *Gauge {
 	labelStr := formatLabels(labels)
-	return &Gauge{
+	g := &Gauge{
 		name:        name,
 		help:        help,
 		labels:      labels,
 		labelString: labelStr,
 	}
+	g.updateMarker.init(time.Now())
+	return g
 }
 
-func (g *Gauge) Set(v float64) {
+func (g *Gauge) SetUnit(unit string) error {
+	if err := validateUnit(g.name, TypeGauge, unit); err != nil {
+		return err
+	}
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	g.value = v
+	g.unit = unit
+	return nil
+}
+
+func (g *Gauge) Set(v float64) {
+	g.valBits.Store(math.Float64bits(v))
+	g.touch()
 }
 
 func (g *Gauge) Inc() {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	g.value++
+	g.Add(1)
 }
 
 func (g *Gauge) Dec() {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	g.value--
+	g.Add(-1)
 }
 
 func (g *Gauge) Add(v float64) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	g.value += v
+	atomicAddFloat(&g.valBits, v)
+	g.touch()
+}
+
+func (g *Gauge) value() float64 {
+	return math.Float64frombits(g.valBits.Load())
 }
 
 func (g *Gauge) Export() string {
+	return exportText(g)
+}
+
+func (g *Gauge) Snapshot() MetricSnapshot {
 	g.mu.Lock()
-	defer g.mu.Unlock()
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("# HELP %s %s\n", g.name, g.help))
-	sb.WriteString(fmt.Sprintf("# TYPE %s gauge\n", g.name))
-	if g.labelString != "" {
-		sb.WriteString(fmt.Sprintf("%s{%s} %s\n", g.name, g.labelString, formatFloat(g.value)))
-	} else {
-		sb.WriteString(fmt.Sprintf("%s %s\n", g.name, formatFloat(g.value)))
+	unit := g.unit
+	g.mu.Unlock()
+	return MetricSnapshot{
+		Name: g.name,
+		Help: g.help,
+		Type: TypeGauge,
+		Unit: unit,
+		Samples: []Sample{{
+			Labels: labelPairs(g.labels),
+			Value:  g.value(),
+		}},
+	}
+}
+
+type GaugeVec struct {
+	*metricVec
+}
+
+func NewGaugeVec(name, help string, labelNames []string) *GaugeVec {
+	return &GaugeVec{
+		metricVec: newMetricVec(name, help, TypeGauge, labelNames, func(labels map[string]string) Metric {
+			return NewGauge(name, help, labels)
+		}),
+	}
+}
+
+func (v *GaugeVec) GetMetricWithLabelValues(values ...string) (*Gauge, error) {
+	metric, err := v.getMetricWithLabelValues(values)
+	if err != nil {
+		return nil, err
+	}
+	return metric.(*Gauge), nil
+}
+
+func (v *GaugeVec) GetMetricWith(labels map[string]string) (*Gauge, error) {
+	metric, err := v.getMetricWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return metric.(*Gauge), nil
+}
+
+func (v *GaugeVec) WithLabelValues(values ...string) *Gauge {
+	gauge, err := v.GetMetricWithLabelValues(values...)
+	if err != nil {
+		panic(err)
+	}
+	return gauge
+}
+
+func (v *GaugeVec) With(labels map[string]string) *Gauge {
+	gauge, err := v.GetMetricWith(labels)
+	if err != nil {
+		panic(err)
 	}
-	return sb.String()
+	return gauge
 }
@@ -4,13 +4,82 @@ package prometheusgin
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	TextContentType        = "text/plain; version=0.0.4; charset=utf-8"
+	OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	ProtobufContentType    = "application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=delimited"
+)
+
+type ExpositionFormat int
+
+const (
+	FormatText ExpositionFormat = iota
+	FormatOpenMetrics
+	FormatProtobuf
+)
+
 func MetricsHandler(reg *MetricRegistry) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		metricsData := reg.ExportAll()
-		c.Data(http.StatusOK, "text/plain; version=0.0.4", []byte(metricsData))
+		switch NegotiateFormat(c.GetHeader("Accept")) {
+		case FormatProtobuf:
+			data, err := reg.ExportAllProtobuf()
+			if err != nil {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+			c.Data(http.StatusOK, ProtobufContentType, data)
+		case FormatOpenMetrics:
+			c.Data(http.StatusOK, OpenMetricsContentType, []byte(reg.ExportAllOpenMetrics()))
+		default:
+			c.Data(http.StatusOK, TextContentType, []byte(reg.ExportAll()))
+		}
+	}
+}
+
+func NegotiateFormat(accept string) ExpositionFormat {
+	format := FormatText
+	bestQ := 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		params := strings.Split(mediaRange, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+		var protoName, encoding string
+		for _, param := range params[1:] {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if !found {
+				continue
+			}
+			value = strings.Trim(strings.TrimSpace(value), `"`)
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "q":
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			case "proto":
+				protoName = value
+			case "encoding":
+				encoding = value
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+		switch mediaType {
+		case "application/vnd.google.protobuf":
+			if protoName == "io.prometheus.client.MetricFamily" && encoding == "delimited" {
+				format, bestQ = FormatProtobuf, q
+			}
+		case "application/openmetrics-text":
+			format, bestQ = FormatOpenMetrics, q
+		case "text/plain", "text/*", "*/*":
+			format, bestQ = FormatText, q
+		}
 	}
+	return format
 }
@@ -1,75 +1,280 @@
 package prometheusgin
 
 import (
-	"fmt"
+	"math"
+	"runtime"
 	"sort"
-	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+type HistogramOption func(*Histogram)
+
+func WithNativeHistogram(schema int32) HistogramOption {
+	return func(h *Histogram) {
+		h.native = true
+		h.nativeSchema = clampNativeSchema(schema)
+	}
+}
+
+func WithNativeHistogramZeroThreshold(threshold float64) HistogramOption {
+	return func(h *Histogram) {
+		if threshold >= 0 {
+			h.nativeZeroThreshold = threshold
+		}
+	}
+}
+
+func WithNativeHistogramMaxBuckets(maxBuckets int) HistogramOption {
+	return func(h *Histogram) {
+		if maxBuckets >= 0 {
+			h.nativeMaxBuckets = uint32(maxBuckets)
+		}
+	}
+}
+
+type histogramCounts struct {
+	count         atomic.Uint64
+	sumBits       atomic.Uint64
+	buckets       []atomic.Uint64
+	schema        atomic.Int32
+	zeroCount     atomic.Uint64
+	positive      sync.Map
+	negative      sync.Map
+	nativeBuckets atomic.Uint32
+}
+
+func (hc *histogramCounts) observe(v float64, bucket int, native bool, zeroThreshold float64) {
+	hc.buckets[bucket].Add(1)
+	atomicAddFloat(&hc.sumBits, v)
+	if native {
+		switch {
+		case math.IsNaN(v):
+		case math.Abs(v) <= zeroThreshold:
+			hc.zeroCount.Add(1)
+		case v > 0:
+			addNativeBucket(&hc.positive, nativeBucketIndex(v, hc.schema.Load()), 1, &hc.nativeBuckets)
+		default:
+			addNativeBucket(&hc.negative, nativeBucketIndex(-v, hc.schema.Load()), 1, &hc.nativeBuckets)
+		}
+	}
+	hc.count.Add(1)
+}
+
+func (hc *histogramCounts) drainInto(dst *histogramCounts) {
+	dst.count.Add(hc.count.Swap(0))
+	atomicAddFloat(&dst.sumBits, math.Float64frombits(hc.sumBits.Swap(0)))
+	for i := range hc.buckets {
+		dst.buckets[i].Add(hc.buckets[i].Swap(0))
+	}
+	dst.zeroCount.Add(hc.zeroCount.Swap(0))
+	from, to := hc.schema.Load(), dst.schema.Load()
+	for key, n := range drainNativeBuckets(&hc.positive) {
+		addNativeBucket(&dst.positive, reduceNativeBucketIndex(key, from, to), n, &dst.nativeBuckets)
+	}
+	for key, n := range drainNativeBuckets(&hc.negative) {
+		addNativeBucket(&dst.negative, reduceNativeBucketIndex(key, from, to), n, &dst.nativeBuckets)
+	}
+	hc.nativeBuckets.Store(0)
+	hc.schema.Store(to)
+}
+
 type Histogram struct {
-	name        string
-	help        string
-	buckets     []float64
-	counts      []int
-	sum         float64
-	labels      map[string]string
-	mu          sync.Mutex
-	labelString string
+	name                string
+	help                string
+	buckets             []float64
+	countAndHotIdx      atomic.Uint64
+	counts              [2]*histogramCounts
+	native              bool
+	nativeSchema        int32
+	nativeZeroThreshold float64
+	nativeMaxBuckets    uint32
+	exemplars           []atomic.Pointer[Exemplar]
+	labels              map[string]string
+	mu                  sync.Mutex
+	labelString         string
+	unit                string
+	created             time.Time
+	updateMarker
 }
 
-func NewHistogram(name, help string, buckets []float64, labels map[string]string) *Histogram {
+func NewHistogram(name, help string, buckets []float64, labels map[string]string, opts ...HistogramOption) *Histogram {
 	labelStr := formatLabels(labels)
-	sort.Float64s(buckets)
-	return &Histogram{
-		name:        name,
-		help:        help,
-		buckets:     append([]float64{}, buckets...),
-		counts:      make([]int, len(buckets)+1),
-		labels:      labels,
-		labelString: labelStr,
+	if n := len(buckets); n > 0 && math.IsInf(buckets[n-1], 1) {
+		buckets = buckets[:n-1]
+	}
+	now := time.Now()
+	h := &Histogram{
+		name:                name,
+		help:                help,
+		buckets:             append([]float64{}, buckets...),
+		exemplars:           make([]atomic.Pointer[Exemplar], len(buckets)+1),
+		nativeSchema:        DefaultNativeHistogramSchema,
+		nativeZeroThreshold: DefaultNativeHistogramZeroThreshold,
+		nativeMaxBuckets:    DefaultNativeHistogramMaxBuckets,
+		labels:              labels,
+		labelString:         labelStr,
+		created:             now,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	for i := range h.counts {
+		h.counts[i] = &histogramCounts{buckets: make([]atomic.Uint64, len(buckets)+1)}
+		h.counts[i].schema.Store(h.nativeSchema)
+	}
+	h.updateMarker.init(now)
+	return h
+}
+
+func (h *Histogram) SetUnit(unit string) error {
+	if err := validateUnit(h.name, TypeHistogram, unit); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.unit = unit
+	return nil
 }
 
 func (h *Histogram) Observe(v float64) {
+	bucket := sort.SearchFloat64s(h.buckets, v)
+	n := h.countAndHotIdx.Add(1)
+	hot := h.counts[n>>63]
+	hot.observe(v, bucket, h.native, h.nativeZeroThreshold)
+	if h.native && h.nativeMaxBuckets > 0 && hot.nativeBuckets.Load() > h.nativeMaxBuckets {
+		h.limitNativeBuckets()
+	}
+	h.touch()
+}
+
+func (h *Histogram) ObserveWithExemplar(v float64, labels map[string]string) {
+	exemplar, err := newExemplar(v, labels)
+	if err != nil {
+		panic(err)
+	}
+	h.Observe(v)
+	h.exemplars[sort.SearchFloat64s(h.buckets, v)].Store(exemplar)
+}
+
+func (h *Histogram) limitNativeBuckets() {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	h.sum += v
-	for i, b := range h.buckets {
-		if v <= b {
-			h.counts[i]++
+	for {
+		n := h.countAndHotIdx.Load()
+		hot := h.counts[n>>63]
+		cold := h.counts[(^n)>>63]
+		schema := hot.schema.Load()
+		if hot.nativeBuckets.Load() <= h.nativeMaxBuckets || schema <= minNativeHistogramSchema {
+			return
 		}
+		cold.schema.Store(schema - 1)
+		hot, cold = h.swapCounts()
+		cold.drainInto(hot)
+	}
+}
+
+func (h *Histogram) swapCounts() (hot, cold *histogramCounts) {
+	n := h.countAndHotIdx.Add(1 << 63)
+	count := n & (1<<63 - 1)
+	hot = h.counts[n>>63]
+	cold = h.counts[(^n)>>63]
+	for cold.count.Load() != count {
+		runtime.Gosched()
 	}
-	h.counts[len(h.counts)-1]++
+	return hot, cold
 }
 
 func (h *Histogram) Export() string {
+	return exportText(h)
+}
+
+func (h *Histogram) Snapshot() MetricSnapshot {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("# HELP %s %s\n", h.name, h.help))
-	sb.WriteString(fmt.Sprintf("# TYPE %s histogram\n", h.name))
-	cumulativeCount := 0
+
+	hot, cold := h.swapCounts()
+
+	buckets := make([]Bucket, 0, len(h.buckets))
+	var cumulativeCount uint64
 	for i, b := range h.buckets {
-		cumulativeCount += h.counts[i]
-		if h.labelString != "" {
-			sb.WriteString(fmt.Sprintf("%s_bucket{%s,le=\"%s\"} %d\n", h.name, h.labelString, formatFloat(b), cumulativeCount))
-		} else {
-			sb.WriteString(fmt.Sprintf("%s_bucket{le=\"%s\"} %d\n", h.name, formatFloat(b), cumulativeCount))
+		cumulativeCount += cold.buckets[i].Load()
+		buckets = append(buckets, Bucket{UpperBound: b, CumulativeCount: cumulativeCount, Exemplar: h.exemplars[i].Load()})
+	}
+	sample := Sample{
+		Labels:   labelPairs(h.labels),
+		Created:  h.created,
+		Count:    cold.count.Load(),
+		Sum:      math.Float64frombits(cold.sumBits.Load()),
+		Buckets:  buckets,
+		Exemplar: h.exemplars[len(h.buckets)].Load(),
+	}
+	if h.native {
+		sample.Native = &NativeHistogram{
+			Schema:        cold.schema.Load(),
+			ZeroThreshold: h.nativeZeroThreshold,
+			ZeroCount:     cold.zeroCount.Load(),
+			Positive:      readNativeBuckets(&cold.positive),
+			Negative:      readNativeBuckets(&cold.negative),
 		}
 	}
-	cumulativeCount += h.counts[len(h.counts)-1]
-	if h.labelString != "" {
-		sb.WriteString(fmt.Sprintf("%s_bucket{%s,le=\"+Inf\"} %d\n", h.name, h.labelString, cumulativeCount))
-	} else {
-		sb.WriteString(fmt.Sprintf("%s_bucket{le=\"+Inf\"} %d\n", h.name, cumulativeCount))
+	cold.drainInto(hot)
+
+	return MetricSnapshot{
+		Name:    h.name,
+		Help:    h.help,
+		Type:    TypeHistogram,
+		Unit:    h.unit,
+		Samples: []Sample{sample},
+	}
+}
+
+type HistogramVec struct {
+	*metricVec
+	buckets []float64
+	native  bool
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labelNames []string, opts ...HistogramOption) *HistogramVec {
+	prototype := NewHistogram(name, help, buckets, nil, opts...)
+	return &HistogramVec{
+		metricVec: newMetricVec(name, help, TypeHistogram, labelNames, func(labels map[string]string) Metric {
+			return NewHistogram(name, help, prototype.buckets, labels, opts...)
+		}),
+		buckets: prototype.buckets,
+		native:  prototype.native,
+	}
+}
+
+func (v *HistogramVec) GetMetricWithLabelValues(values ...string) (*Histogram, error) {
+	metric, err := v.getMetricWithLabelValues(values)
+	if err != nil {
+		return nil, err
+	}
+	return metric.(*Histogram), nil
+}
+
+func (v *HistogramVec) GetMetricWith(labels map[string]string) (*Histogram, error) {
+	metric, err := v.getMetricWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return metric.(*Histogram), nil
+}
+
+func (v *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	histogram, err := v.GetMetricWithLabelValues(values...)
+	if err != nil {
+		panic(err)
 	}
-	if h.labelString != "" {
-		sb.WriteString(fmt.Sprintf("%s_sum{%s} %s\n", h.name, h.labelString, formatFloat(h.sum)))
-		sb.WriteString(fmt.Sprintf("%s_count{%s} %d\n", h.name, h.labelString, cumulativeCount))
-	} else {
-		sb.WriteString(fmt.Sprintf("%s_sum %s\n", h.name, formatFloat(h.sum)))
-		sb.WriteString(fmt.Sprintf("%s_count %d\n", h.name, cumulativeCount))
+	return histogram
+}
+
+func (v *HistogramVec) With(labels map[string]string) *Histogram {
+	histogram, err := v.GetMetricWith(labels)
+	if err != nil {
+		panic(err)
 	}
-	return sb.String()
+	return histogram
 }
@@ -0,0 +1,81 @@
+package prometheusgin
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestHistogramSnapshotConsistentUnderConcurrentObserve(t *testing.T) {
+	h := NewHistogram("test_seconds", "Test histogram.", []float64{0.5, 1, 2}, nil)
+
+	const workers, perWorker = 8, 10000
+	stop := make(chan struct{})
+	scraped := make(chan struct{})
+	go func() {
+		defer close(scraped)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			sample := h.Snapshot().Samples[0]
+			if sample.Sum != float64(sample.Count) {
+				t.Errorf("sum %v does not match count %d", sample.Sum, sample.Count)
+				return
+			}
+			if sample.Buckets[0].CumulativeCount != 0 || sample.Buckets[1].CumulativeCount != sample.Count || sample.Buckets[2].CumulativeCount != sample.Count {
+				t.Errorf("buckets %+v do not match count %d", sample.Buckets, sample.Count)
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				h.Observe(1)
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-scraped
+
+	sample := h.Snapshot().Samples[0]
+	if sample.Count != workers*perWorker {
+		t.Fatalf("count = %d, want %d", sample.Count, workers*perWorker)
+	}
+	if sample.Sum != workers*perWorker {
+		t.Fatalf("sum = %v, want %d", sample.Sum, workers*perWorker)
+	}
+}
+
+func TestHistogramObserveBuckets(t *testing.T) {
+	h := NewHistogram("test_seconds", "Test histogram.", []float64{1, 2, 5}, nil)
+	for _, v := range []float64{0.5, 1, 1.5, 2, 4.9, 5, 7} {
+		h.Observe(v)
+	}
+	sample := h.Snapshot().Samples[0]
+	want := []uint64{2, 4, 6}
+	for i, b := range sample.Buckets {
+		if b.CumulativeCount != want[i] {
+			t.Errorf("bucket le=%v = %d, want %d", b.UpperBound, b.CumulativeCount, want[i])
+		}
+	}
+	if sample.Count != 7 {
+		t.Errorf("count = %d, want 7", sample.Count)
+	}
+	if sample.Sum != 21.9 {
+		t.Errorf("sum = %v, want 21.9", sample.Sum)
+	}
+
+	// A second snapshot must see the same totals after the hot/cold merge.
+	again := h.Snapshot().Samples[0]
+	if again.Count != sample.Count || again.Sum != sample.Sum || again.Buckets[2].CumulativeCount != 6 {
+		t.Errorf("second snapshot %+v differs from first %+v", again, sample)
+	}
+}
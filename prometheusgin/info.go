@@ -2,46 +2,103 @@ package prometheusgin
 
 import (
 	"fmt"
-	"strings"
+	"runtime"
+	"runtime/debug"
 	"sync"
 )
 
 type Info struct {
 	name        string
 	help        string
-	info        string
+	info        map[string]string
 	labels      map[string]string
 	mu          sync.Mutex
 	labelString string
 }
 
-func NewInfo(name, help string, info string, labels map[string]string) *Info {
+func NewInfo(name, help string, info map[string]string, labels map[string]string) *Info {
 	labelStr := formatLabels(labels)
 	return &Info{
 		name:        name,
 		help:        help,
-		info:        info,
+		info:        copyLabels(info),
 		labels:      labels,
 		labelString: labelStr,
 	}
 }
 
-func (i *Info) SetInfo(v string) {
+func NewBuildInfo(name, help string) *Info {
+	info := map[string]string{
+		"goversion": runtime.Version(),
+		"path":      "unknown",
+		"version":   "unknown",
+		"revision":  "unknown",
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if build.GoVersion != "" {
+			info["goversion"] = build.GoVersion
+		}
+		if build.Main.Path != "" {
+			info["path"] = build.Main.Path
+		}
+		if build.Main.Version != "" {
+			info["version"] = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			if setting.Key == "vcs.revision" && setting.Value != "" {
+				info["revision"] = setting.Value
+			}
+		}
+	}
+	return NewInfo(name, help, info, nil)
+}
+
+func (i *Info) SetInfo(info map[string]string) error {
 	i.mu.Lock()
 	defer i.mu.Unlock()
-	i.info = v
+	for name := range info {
+		if _, ok := i.info[name]; !ok {
+			return fmt.Errorf("info %q: label %q was not declared when the metric was created", i.name, name)
+		}
+	}
+	for name, value := range info {
+		i.info[name] = value
+	}
+	return nil
+}
+
+func (i *Info) infoLabelNames() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return labelNamesOf(i.info)
 }
 
 func (i *Info) Export() string {
+	return exportText(i)
+}
+
+func (i *Info) Snapshot() MetricSnapshot {
 	i.mu.Lock()
 	defer i.mu.Unlock()
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("# HELP %s %s\n", i.name, i.help))
-	sb.WriteString(fmt.Sprintf("# TYPE %s info\n", i.name))
-	if i.labelString != "" {
-		sb.WriteString(fmt.Sprintf("%s{%s} \"%s\"\n", i.name, i.labelString, i.info))
-	} else {
-		sb.WriteString(fmt.Sprintf("%s \"%s\"\n", i.name, i.info))
-	}
-	return sb.String()
+	extra := make([]string, 0, len(i.info)*2)
+	for name, value := range i.info {
+		extra = append(extra, name, value)
+	}
+	return MetricSnapshot{
+		Name: i.name,
+		Help: i.help,
+		Type: TypeInfo,
+		Samples: []Sample{{
+			Labels: labelPairs(i.labels, extra...),
+			Value:  1,
+		}},
+	}
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	copied := make(map[string]string, len(labels))
+	for name, value := range labels {
+		copied[name] = value
+	}
+	return copied
 }
@@ -0,0 +1,78 @@
+package prometheusgin
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestInfoExposition(t *testing.T) {
+	reg := NewMetricRegistry()
+	reg.MustRegister(NewInfo("app", "Application information.", map[string]string{"version": "1.2"}, map[string]string{"env": "prod"}))
+
+	if text := reg.ExportAll(); !strings.Contains(text, "# TYPE app_info gauge\napp_info{env=\"prod\",version=\"1.2\"} 1\n") {
+		t.Errorf("text exposition missing app_info series:\n%s", text)
+	}
+	if om := reg.ExportAllOpenMetrics(); !strings.Contains(om, "# TYPE app info\n") || !strings.Contains(om, "app_info{env=\"prod\",version=\"1.2\"} 1\n") {
+		t.Errorf("OpenMetrics exposition missing app_info series:\n%s", om)
+	}
+}
+
+func TestInfoSetInfoKeepsDeclaredLabels(t *testing.T) {
+	info := NewInfo("app", "Application information.", map[string]string{"version": "1.2", "commit": "abc"}, nil)
+	if err := info.SetInfo(map[string]string{"version": "1.3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := info.SetInfo(map[string]string{"branch": "main"}); err == nil {
+		t.Fatal("SetInfo added an undeclared label, want an error")
+	}
+
+	sample := info.Snapshot().Samples[0]
+	got := map[string]string{}
+	for _, label := range sample.Labels {
+		got[label.Name] = label.Value
+	}
+	if len(got) != 2 || got["version"] != "1.3" || got["commit"] != "abc" {
+		t.Fatalf("labels = %v, want version=1.3 and commit=abc", got)
+	}
+}
+
+func TestInfoSetInfoConcurrentWithRegister(t *testing.T) {
+	reg := NewMetricRegistry()
+	info := NewInfo("app", "Application information.", map[string]string{"version": "1"}, nil)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			info.SetInfo(map[string]string{"version": "2"})
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		reg.Register(info)
+	}
+	wg.Wait()
+}
+
+func TestNewBuildInfo(t *testing.T) {
+	info := NewBuildInfo("app_build", "Build information.")
+	reg := NewMetricRegistry()
+	reg.MustRegister(info)
+
+	got := map[string]string{}
+	for _, label := range info.Snapshot().Samples[0].Labels {
+		got[label.Name] = label.Value
+	}
+	for _, name := range []string{"goversion", "path", "version", "revision"} {
+		if got[name] == "" {
+			t.Errorf("build info label %q is empty", name)
+		}
+	}
+	if got["goversion"] != runtime.Version() {
+		t.Errorf("goversion = %q, want %q", got["goversion"], runtime.Version())
+	}
+	if text := reg.ExportAll(); !strings.Contains(text, "app_build_info{goversion=") || !strings.HasSuffix(text, "} 1\n") {
+		t.Errorf("text exposition missing app_build_info series:\n%s", text)
+	}
+}
@@ -0,0 +1,109 @@
+// prometheusgin/logger.go
+
+package prometheusgin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "DEBUG"
+	case LogLevelInfo:
+		return "INFO"
+	case LogLevelWarn:
+		return "WARN"
+	case LogLevelError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
+}
+
+const (
+	LogFieldMethod    = "method"
+	LogFieldPath      = "path"
+	LogFieldRoute     = "route"
+	LogFieldStatus    = "status"
+	LogFieldDuration  = "duration"
+	LogFieldClientIP  = "client_ip"
+	LogFieldUserAgent = "user_agent"
+	LogFieldSize      = "size"
+)
+
+type LogField struct {
+	Key   string
+	Value interface{}
+}
+
+type Logger interface {
+	Log(ctx context.Context, level LogLevel, msg string, fields ...LogField)
+}
+
+type stdLogger struct {
+	logger *log.Logger
+}
+
+func NewStdLogger(logger *log.Logger) Logger {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &stdLogger{logger: logger}
+}
+
+func (l *stdLogger) Log(_ context.Context, level LogLevel, msg string, fields ...LogField) {
+	var sb strings.Builder
+	sb.WriteString(level.String())
+	sb.WriteString(" ")
+	sb.WriteString(msg)
+	for _, field := range fields {
+		sb.WriteString(fmt.Sprintf(" %s=%v", field.Key, field.Value))
+	}
+	l.logger.Print(sb.String())
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func NewSlogLogger(logger *slog.Logger) Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &slogLogger{logger: logger}
+}
+
+func (l *slogLogger) Log(ctx context.Context, level LogLevel, msg string, fields ...LogField) {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, field := range fields {
+		attrs = append(attrs, slog.Any(field.Key, field.Value))
+	}
+	l.logger.LogAttrs(ctx, slogLevel(level), msg, attrs...)
+}
+
+func slogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelWarn:
+		return slog.LevelWarn
+	case LogLevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -7,8 +7,21 @@ import (
 	"strings"
 )
 
+var (
+	labelValueEscaper      = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper            = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	openMetricsHelpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
 type Metric interface {
 	Export() string
+	Snapshot() MetricSnapshot
+}
+
+func exportText(metric Metric) string {
+	var sb strings.Builder
+	writeText(&sb, metric.Snapshot())
+	return sb.String()
 }
 
 func formatLabels(labels map[string]string) string {
@@ -22,11 +35,34 @@ func formatLabels(labels map[string]string) string {
 	}
 	sort.Strings(keys)
 	for _, k := range keys {
-		parts = append(parts, fmt.Sprintf(`%s="%s"`, k, labels[k]))
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, k, escapeLabelValue(labels[k])))
 	}
 	return strings.Join(parts, ",")
 }
 
+func formatLabelPairs(labels []LabelPair, extra ...string) string {
+	parts := make([]string, 0, len(labels)+len(extra)/2)
+	for _, label := range labels {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, label.Name, escapeLabelValue(label.Value)))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, extra[i], escapeLabelValue(extra[i+1])))
+	}
+	return strings.Join(parts, ",")
+}
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(strings.ToValidUTF8(v, "\uFFFD"))
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(strings.ToValidUTF8(help, "\uFFFD"))
+}
+
+func escapeOpenMetricsHelp(help string) string {
+	return openMetricsHelpEscaper.Replace(strings.ToValidUTF8(help, "\uFFFD"))
+}
+
 func formatFloat(v float64) string {
 	return strconv.FormatFloat(v, 'f', -1, 64)
 }
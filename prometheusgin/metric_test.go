@@ -0,0 +1,104 @@
+package prometheusgin
+
+import (
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+func FuzzTextExposition(f *testing.F) {
+	f.Add("value", "help text")
+	f.Add(`quote " backslash \ newline`+"\n", `help \ with "quotes"`+"\nand newlines")
+	f.Add("\xff\xfe invalid utf8", "\x00\x80")
+	f.Add(`\n literal`, `\\n`)
+	f.Add("", "")
+	f.Fuzz(func(t *testing.T, value, help string) {
+		reg := NewMetricRegistry()
+		counter := NewCounter("fuzz_total", help, map[string]string{"label": value})
+		if err := reg.Register(counter); err != nil {
+			t.Fatal(err)
+		}
+		counter.Inc()
+
+		wantValue := strings.ToValidUTF8(value, "\uFFFD")
+		// The text format cannot carry leading blanks in HELP; parsers skip them.
+		wantHelp := strings.TrimLeft(strings.ToValidUTF8(help, "\uFFFD"), " \t")
+
+		text := reg.ExportAll()
+		checkFamily(t, "text", text, wantValue, wantHelp)
+
+		openMetrics := reg.ExportAllOpenMetrics()
+		checkFamily(t, "openmetrics", openMetricsAsText(t, openMetrics), wantValue, wantHelp)
+	})
+}
+
+func checkFamily(t *testing.T, format, exposition, wantValue, wantHelp string) {
+	t.Helper()
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(exposition))
+	if err != nil {
+		t.Fatalf("%s: parse error: %v\n%s", format, err, exposition)
+	}
+	family, ok := families["fuzz_total"]
+	if !ok {
+		t.Fatalf("%s: family fuzz_total missing\n%s", format, exposition)
+	}
+	if family.GetHelp() != wantHelp {
+		t.Fatalf("%s: help = %q, want %q", format, family.GetHelp(), wantHelp)
+	}
+	if len(family.Metric) != 1 {
+		t.Fatalf("%s: got %d series, want 1", format, len(family.Metric))
+	}
+	if got := labelValue(family.Metric[0], "label"); got != wantValue {
+		t.Fatalf("%s: label value = %q, want %q", format, got, wantValue)
+	}
+	if got := family.Metric[0].GetCounter().GetValue(); got != 1 {
+		t.Fatalf("%s: value = %v, want 1", format, got)
+	}
+}
+
+func labelValue(metric *dto.Metric, name string) string {
+	for _, label := range metric.Label {
+		if label.GetName() == name {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
+
+func openMetricsAsText(t *testing.T, exposition string) string {
+	t.Helper()
+	body, ok := strings.CutSuffix(exposition, "# EOF\n")
+	if !ok {
+		t.Fatalf("openmetrics: missing # EOF terminator\n%s", exposition)
+	}
+	var sb strings.Builder
+	for _, line := range strings.Split(body, "\n") {
+		switch {
+		case line == "", strings.HasPrefix(line, "fuzz_created"):
+		case strings.HasPrefix(line, "# TYPE fuzz "):
+			sb.WriteString("# TYPE fuzz_total counter\n")
+		case strings.HasPrefix(line, "# HELP fuzz "):
+			sb.WriteString("# HELP fuzz_total " + openMetricsHelpAsText(strings.TrimPrefix(line, "# HELP fuzz ")) + "\n")
+		default:
+			sb.WriteString(line + "\n")
+		}
+	}
+	return sb.String()
+}
+
+func openMetricsHelpAsText(help string) string {
+	var sb strings.Builder
+	for i := 0; i < len(help); i++ {
+		if help[i] == '\\' && i+1 < len(help) {
+			i++
+			if help[i] != '"' {
+				sb.WriteByte('\\')
+			}
+		}
+		sb.WriteByte(help[i])
+	}
+	return sb.String()
+}
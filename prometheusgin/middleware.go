@@ -3,7 +3,16 @@
 package prometheusgin
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -23,170 +32,548 @@ type MetricUpdate struct {
 	Name      string
 	Labels    map[string]string
 	Value     float64
+	Exemplar  map[string]string
 	MetricPtr interface{}
 }
 
-func PrometheusMiddleware(reg *MetricRegistry) gin.HandlerFunc {
-	updateChan := make(chan MetricUpdate, 1000)
-	go processMetricUpdates(reg, updateChan)
+type UpdatePolicy int
 
+const (
+	UpdateBlock UpdatePolicy = iota
+	UpdateDropNewest
+	UpdateDropOldest
+	UpdateSynchronous
+)
+
+type HTTPMetric int
+
+const (
+	RequestsTotal HTTPMetric = iota
+	ErrorsTotal
+	ActiveRequests
+	RequestDuration
+	RequestSize
+	ResponseSize
+)
+
+const (
+	LabelMethod = "method"
+	LabelPath   = "path"
+	LabelCode   = "code"
+	LabelClass  = "class"
+)
+
+var defaultSizeBuckets = []float64{100, 1000, 10000, 100000, 1e6, 1e7, 1e8}
+
+type httpMetricConfig struct {
+	name    string
+	help    string
+	enabled bool
+}
+
+type Middleware struct {
+	registry        *MetricRegistry
+	queueSize       int
+	policy          UpdatePolicy
+	namespace       string
+	subsystem       string
+	metrics         map[HTTPMetric]*httpMetricConfig
+	durationBuckets []float64
+	requestBuckets  []float64
+	responseBuckets []float64
+	histogramOpts   []HistogramOption
+	labels          []string
+	constLabels     map[string]string
+	extraLabels     []string
+	labelExtractor  func(c *gin.Context) []string
+	codeMapper      func(status int) string
+	classMapper     func(status int) string
+	skipRules       []func(c *gin.Context) bool
+	sampleRate      float64
+	sampledRoutes   []string
+	unmatchedRoute  string
+	logger          Logger
+	logLevel        LogLevel
+	logFields       []string
+	traceID         func(c *gin.Context) string
+
+	requests     *CounterVec
+	errors       *CounterVec
+	active       *GaugeVec
+	duration     *HistogramVec
+	requestSize  *HistogramVec
+	responseSize *HistogramVec
+	dropped      *Counter
+	queueDepth   *Gauge
+	labelErrors  *Counter
+
+	updates   chan MetricUpdate
+	closed    bool
+	mu        sync.RWMutex
+	closeOnce sync.Once
+	done      chan struct{}
+	unhook    func()
+}
+
+func NewMiddleware(reg *MetricRegistry, opts ...MiddlewareOption) *Middleware {
+	m := &Middleware{
+		registry:  reg,
+		queueSize: 1000,
+		policy:    UpdateBlock,
+		metrics: map[HTTPMetric]*httpMetricConfig{
+			RequestsTotal:   {name: "http_requests_total", help: "Total number of HTTP requests.", enabled: true},
+			ErrorsTotal:     {name: "http_errors_total", help: "Total number of HTTP requests that returned a 4xx or 5xx status.", enabled: true},
+			ActiveRequests:  {name: "active_requests", help: "Number of HTTP requests currently being served.", enabled: true},
+			RequestDuration: {name: "http_latency_seconds_total", help: "HTTP request latency in seconds.", enabled: true},
+			RequestSize:     {name: "http_request_size_bytes", help: "HTTP request body size in bytes."},
+			ResponseSize:    {name: "http_response_size_bytes", help: "HTTP response body size in bytes."},
+		},
+		durationBuckets: []float64{0.1, 0.3, 1.2, 5.0},
+		requestBuckets:  defaultSizeBuckets,
+		responseBuckets: defaultSizeBuckets,
+		labels:          []string{LabelMethod, LabelPath, LabelCode},
+		codeMapper:      strconv.Itoa,
+		classMapper:     statusClass,
+		sampleRate:      1,
+		unmatchedRoute:  "unmatched",
+		logLevel:        LogLevelInfo,
+		logFields:       []string{LogFieldMethod, LogFieldPath, LogFieldStatus, LogFieldDuration},
+		done:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	for _, label := range m.labels {
+		switch label {
+		case LabelMethod, LabelPath, LabelCode, LabelClass:
+		default:
+			panic(fmt.Errorf("unsupported middleware label %q", label))
+		}
+	}
+
+	routeLabelNames := m.labelNames(false)
+	statusLabelNames := m.labelNames(true)
+	if m.enabled(RequestsTotal) {
+		m.requests = mustRegisterShared(reg, NewCounterVec(m.metricName(RequestsTotal), m.metrics[RequestsTotal].help, statusLabelNames)).(*CounterVec)
+	}
+	if m.enabled(ErrorsTotal) {
+		m.errors = mustRegisterShared(reg, NewCounterVec(m.metricName(ErrorsTotal), m.metrics[ErrorsTotal].help, statusLabelNames)).(*CounterVec)
+	}
+	if m.enabled(ActiveRequests) {
+		m.active = mustRegisterShared(reg, NewGaugeVec(m.metricName(ActiveRequests), m.metrics[ActiveRequests].help, routeLabelNames)).(*GaugeVec)
+		m.active.disableExpiry()
+	}
+	if m.enabled(RequestDuration) {
+		m.duration = mustRegisterShared(reg, NewHistogramVec(m.metricName(RequestDuration), m.metrics[RequestDuration].help, m.durationBuckets, statusLabelNames, m.histogramOpts...)).(*HistogramVec)
+	}
+	if m.enabled(RequestSize) {
+		m.requestSize = mustRegisterShared(reg, NewHistogramVec(m.metricName(RequestSize), m.metrics[RequestSize].help, m.requestBuckets, statusLabelNames, m.histogramOpts...)).(*HistogramVec)
+	}
+	if m.enabled(ResponseSize) {
+		m.responseSize = mustRegisterShared(reg, NewHistogramVec(m.metricName(ResponseSize), m.metrics[ResponseSize].help, m.responseBuckets, statusLabelNames, m.histogramOpts...)).(*HistogramVec)
+	}
+	m.dropped = mustGetOrCreateCounter(reg, "prometheusgin_dropped_updates_total", "Metric updates dropped because the update queue was full.")
+	m.queueDepth = mustGetOrCreateGauge(reg, "prometheusgin_update_queue_depth", "Metric updates waiting in the update queue.")
+	if m.labelExtractor != nil {
+		m.labelErrors = mustGetOrCreateCounter(reg, "prometheusgin_label_extraction_errors_total", "Requests left unrecorded because the label extractor returned the wrong number of values.")
+	}
+
+	if m.policy == UpdateSynchronous {
+		close(m.done)
+		return m
+	}
+	m.updates = make(chan MetricUpdate, m.queueSize)
+	go m.run()
+	m.unhook = reg.onClose(m.Close)
+	return m
+}
+
+func PrometheusMiddleware(reg *MetricRegistry, opts ...MiddlewareOption) gin.HandlerFunc {
+	return NewMiddleware(reg, opts...).Handler()
+}
+
+func PrometheusMiddlewareWithSize(reg *MetricRegistry, size int) gin.HandlerFunc {
+	return NewMiddleware(reg, WithQueueSize(size)).Handler()
+}
+
+func (m *Middleware) Handler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if m.skip(c) {
+			c.Next()
+			return
+		}
+
 		start := time.Now()
+		extra, ok := m.extractLabels(c)
+		if !ok {
+			m.labelErrors.Inc()
+			c.Next()
+			return
+		}
+		routeLabels := m.requestLabels(c, 0, extra)
+		var body *countingReadCloser
+		if m.requestSize != nil && c.Request.ContentLength < 0 && c.Request.Body != nil && c.Request.Body != http.NoBody {
+			body = &countingReadCloser{ReadCloser: c.Request.Body}
+			c.Request.Body = body
+		}
 
-		updateChan <- MetricUpdate{
-			Type:   GaugeIncrement,
-			Name:   "active_requests",
-			Labels: map[string]string{"method": c.Request.Method, "path": c.FullPath()},
-			Value:  1,
+		if m.active != nil {
+			m.send(MetricUpdate{
+				Type:      GaugeIncrement,
+				Name:      m.active.name,
+				Labels:    routeLabels,
+				Value:     1,
+				MetricPtr: m.active,
+			})
 		}
 
 		c.Next()
 
 		duration := time.Since(start).Seconds()
+		status := c.Writer.Status()
+		statusLabels := m.requestLabels(c, status, extra)
+		var exemplar map[string]string
+		if m.traceID != nil {
+			exemplar = exemplarLabels(m.traceID(c))
+		}
 
-		updateChan <- MetricUpdate{
-			Type:   GaugeDecrement,
-			Name:   "active_requests",
-			Labels: map[string]string{"method": c.Request.Method, "path": c.FullPath()},
-			Value:  -1,
+		if m.active != nil {
+			m.send(MetricUpdate{
+				Type:      GaugeDecrement,
+				Name:      m.active.name,
+				Labels:    routeLabels,
+				Value:     -1,
+				MetricPtr: m.active,
+			})
 		}
 
-		updateChan <- MetricUpdate{
-			Type:   CounterIncrement,
-			Name:   "http_requests_total",
-			Labels: map[string]string{"method": c.Request.Method, "path": c.FullPath()},
-			Value:  1,
+		if m.requests != nil {
+			m.send(MetricUpdate{
+				Type:      CounterIncrement,
+				Name:      m.requests.name,
+				Labels:    statusLabels,
+				Value:     1,
+				Exemplar:  exemplar,
+				MetricPtr: m.requests,
+			})
 		}
 
-		if c.Writer.Status() >= 400 {
-			updateChan <- MetricUpdate{
-				Type:   CounterIncrement,
-				Name:   "http_errors_total",
-				Labels: map[string]string{"method": c.Request.Method, "path": c.FullPath()},
-				Value:  1,
+		if m.errors != nil && status >= 400 {
+			m.send(MetricUpdate{
+				Type:      CounterIncrement,
+				Name:      m.errors.name,
+				Labels:    statusLabels,
+				Value:     1,
+				Exemplar:  exemplar,
+				MetricPtr: m.errors,
+			})
+		}
+
+		if m.duration != nil && m.sampled(c) {
+			m.send(MetricUpdate{
+				Type:      HistogramObserve,
+				Name:      m.duration.name,
+				Labels:    statusLabels,
+				Value:     duration,
+				Exemplar:  exemplar,
+				MetricPtr: m.duration,
+			})
+		}
+
+		if m.requestSize != nil {
+			size := c.Request.ContentLength
+			if body != nil {
+				size = body.n
 			}
+			if size < 0 {
+				size = 0
+			}
+			m.send(MetricUpdate{
+				Type:      HistogramObserve,
+				Name:      m.requestSize.name,
+				Labels:    statusLabels,
+				Value:     float64(size),
+				MetricPtr: m.requestSize,
+			})
 		}
 
-		updateChan <- MetricUpdate{
-			Type:   HistogramObserve,
-			Name:   "http_latency_seconds_total",
-			Labels: map[string]string{"method": c.Request.Method, "path": c.FullPath()},
-			Value:  duration,
+		if m.responseSize != nil {
+			size := c.Writer.Size()
+			if size < 0 {
+				size = 0
+			}
+			m.send(MetricUpdate{
+				Type:      HistogramObserve,
+				Name:      m.responseSize.name,
+				Labels:    statusLabels,
+				Value:     float64(size),
+				MetricPtr: m.responseSize,
+			})
 		}
 
-		log.Printf("Request %s %s - Status: %d, Duration: %f seconds", c.Request.Method, c.Request.URL.Path, c.Writer.Status(), duration)
+		if m.logger != nil {
+			m.logRequest(c, status, duration)
+		}
 	}
 }
 
-func PrometheusMiddlewareWithSize(reg *MetricRegistry, size int) gin.HandlerFunc {
-	updateChan := make(chan MetricUpdate, size)
-	go processMetricUpdates(reg, updateChan)
+func (m *Middleware) Close() {
+	m.closeOnce.Do(func() {
+		m.mu.Lock()
+		m.closed = true
+		if m.updates != nil {
+			close(m.updates)
+		}
+		m.mu.Unlock()
+		<-m.done
+		if m.unhook != nil {
+			m.unhook()
+		}
+	})
+}
 
-	return func(c *gin.Context) {
-		start := time.Now()
+func (m *Middleware) enabled(metric HTTPMetric) bool {
+	config, ok := m.metrics[metric]
+	return ok && config.enabled
+}
 
-		updateChan <- MetricUpdate{
-			Type:   GaugeIncrement,
-			Name:   "active_requests",
-			Labels: map[string]string{"method": c.Request.Method, "path": c.FullPath()},
-			Value:  1,
+func (m *Middleware) metricName(metric HTTPMetric) string {
+	var parts []string
+	for _, part := range []string{m.namespace, m.subsystem, m.metrics[metric].name} {
+		if part != "" {
+			parts = append(parts, part)
 		}
+	}
+	return strings.Join(parts, "_")
+}
 
-		c.Next()
+func (m *Middleware) logRequest(c *gin.Context, status int, duration float64) {
+	fields := make([]LogField, 0, len(m.logFields))
+	for _, key := range m.logFields {
+		switch key {
+		case LogFieldMethod:
+			fields = append(fields, LogField{Key: key, Value: c.Request.Method})
+		case LogFieldPath:
+			fields = append(fields, LogField{Key: key, Value: c.Request.URL.Path})
+		case LogFieldRoute:
+			fields = append(fields, LogField{Key: key, Value: m.routeLabel(c)})
+		case LogFieldStatus:
+			fields = append(fields, LogField{Key: key, Value: status})
+		case LogFieldDuration:
+			fields = append(fields, LogField{Key: key, Value: duration})
+		case LogFieldClientIP:
+			fields = append(fields, LogField{Key: key, Value: c.ClientIP()})
+		case LogFieldUserAgent:
+			fields = append(fields, LogField{Key: key, Value: c.Request.UserAgent()})
+		case LogFieldSize:
+			fields = append(fields, LogField{Key: key, Value: c.Writer.Size()})
+		}
+	}
+	m.logger.Log(c.Request.Context(), m.logLevel, "request", fields...)
+}
 
-		duration := time.Since(start).Seconds()
+func (m *Middleware) skip(c *gin.Context) bool {
+	for _, rule := range m.skipRules {
+		if rule(c) {
+			return true
+		}
+	}
+	return false
+}
 
-		updateChan <- MetricUpdate{
-			Type:   GaugeDecrement,
-			Name:   "active_requests",
-			Labels: map[string]string{"method": c.Request.Method, "path": c.FullPath()},
-			Value:  -1,
+func (m *Middleware) sampled(c *gin.Context) bool {
+	if m.sampleRate >= 1 {
+		return true
+	}
+	if len(m.sampledRoutes) > 0 && !matchRoute(m.sampledRoutes, c.FullPath()) {
+		return true
+	}
+	return rand.Float64() < m.sampleRate
+}
+
+func matchRoute(patterns []string, route string) bool {
+	for _, pattern := range patterns {
+		if pattern == route {
+			return true
+		}
+		if matched, err := path.Match(pattern, route); err == nil && matched {
+			return true
 		}
+	}
+	return false
+}
 
-		updateChan <- MetricUpdate{
-			Type:   CounterIncrement,
-			Name:   "http_requests_total",
-			Labels: map[string]string{"method": c.Request.Method, "path": c.FullPath()},
-			Value:  1,
+func (m *Middleware) labelNames(withStatus bool) []string {
+	var names []string
+	for _, label := range m.labels {
+		if (label != LabelCode && label != LabelClass) || withStatus {
+			names = append(names, label)
 		}
+	}
+	names = append(names, m.extraLabels...)
+	constNames := make([]string, 0, len(m.constLabels))
+	for name := range m.constLabels {
+		constNames = append(constNames, name)
+	}
+	sort.Strings(constNames)
+	return append(names, constNames...)
+}
+
+func (m *Middleware) routeLabel(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+	return m.unmatchedRoute
+}
+
+func (m *Middleware) extractLabels(c *gin.Context) ([]string, bool) {
+	if m.labelExtractor == nil {
+		return nil, true
+	}
+	values := m.labelExtractor(c)
+	return values, len(values) == len(m.extraLabels)
+}
 
-		if c.Writer.Status() >= 400 {
-			updateChan <- MetricUpdate{
-				Type:   CounterIncrement,
-				Name:   "http_errors_total",
-				Labels: map[string]string{"method": c.Request.Method, "path": c.FullPath()},
-				Value:  1,
+func (m *Middleware) requestLabels(c *gin.Context, status int, extra []string) map[string]string {
+	labels := make(map[string]string, len(m.labels)+len(extra)+len(m.constLabels))
+	for _, label := range m.labels {
+		switch label {
+		case LabelMethod:
+			labels[label] = c.Request.Method
+		case LabelPath:
+			labels[label] = m.routeLabel(c)
+		case LabelCode:
+			if status != 0 {
+				labels[label] = m.codeMapper(status)
+			}
+		case LabelClass:
+			if status != 0 {
+				labels[label] = m.classMapper(status)
 			}
 		}
+	}
+	for i, value := range extra {
+		labels[m.extraLabels[i]] = value
+	}
+	for name, value := range m.constLabels {
+		labels[name] = value
+	}
+	return labels
+}
 
-		updateChan <- MetricUpdate{
-			Type:   HistogramObserve,
-			Name:   "http_latency_seconds_total",
-			Labels: map[string]string{"method": c.Request.Method, "path": c.FullPath()},
-			Value:  duration,
+func (m *Middleware) send(update MetricUpdate) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed || m.policy == UpdateSynchronous || update.Type == GaugeIncrement || update.Type == GaugeDecrement {
+		m.apply(update)
+		return
+	}
+	m.queueDepth.Inc()
+	switch m.policy {
+	case UpdateDropNewest:
+		select {
+		case m.updates <- update:
+		default:
+			m.queueDepth.Dec()
+			m.dropped.Inc()
+		}
+	case UpdateDropOldest:
+		for {
+			select {
+			case m.updates <- update:
+				return
+			default:
+			}
+			select {
+			case <-m.updates:
+				m.queueDepth.Dec()
+				m.dropped.Inc()
+			default:
+			}
+		}
+	default:
+		select {
+		case m.updates <- update:
+		default:
+			m.queueDepth.Dec()
+			m.updates <- update
+			m.queueDepth.Inc()
 		}
+	}
+}
 
-		log.Printf("Request %s %s - Status: %d, Duration: %f seconds", c.Request.Method, c.Request.URL.Path, c.Writer.Status(), duration)
+func (m *Middleware) run() {
+	defer close(m.done)
+	for update := range m.updates {
+		m.queueDepth.Dec()
+		m.apply(update)
 	}
 }
 
-func processMetricUpdates(reg *MetricRegistry, updates chan MetricUpdate) {
-	for update := range updates {
-		switch update.Type {
-		case CounterIncrement:
-			counter := getOrCreateCounter(reg, update.Name, "A counter metric", update.Labels)
-			counter.Add(update.Value)
-		case GaugeIncrement:
-			gauge := getOrCreateGauge(reg, update.Name, "A gauge metric", update.Labels)
-			gauge.Add(update.Value)
-		case GaugeDecrement:
-			gauge := getOrCreateGauge(reg, update.Name, "A gauge metric", update.Labels)
+func (m *Middleware) apply(update MetricUpdate) {
+	switch metric := update.MetricPtr.(type) {
+	case *CounterVec:
+		if counter, err := metric.GetMetricWith(update.Labels); err == nil {
+			if update.Exemplar != nil {
+				counter.AddWithExemplar(update.Value, update.Exemplar)
+			} else {
+				counter.Add(update.Value)
+			}
+		}
+	case *GaugeVec:
+		if gauge, err := metric.GetMetricWith(update.Labels); err == nil {
 			gauge.Add(update.Value)
-		case HistogramObserve:
-			histogram := getOrCreateHistogram(reg, update.Name, "A histogram metric", []float64{0.1, 0.3, 1.2, 5.0}, update.Labels)
-			histogram.Observe(update.Value)
+		}
+	case *HistogramVec:
+		if histogram, err := metric.GetMetricWith(update.Labels); err == nil {
+			if update.Exemplar != nil {
+				histogram.ObserveWithExemplar(update.Value, update.Exemplar)
+			} else {
+				histogram.Observe(update.Value)
+			}
 		}
 	}
 }
 
-func getOrCreateCounter(reg *MetricRegistry, name, help string, labels map[string]string) *Counter {
-	reg.mu.RLock()
-	metricsList, exists := reg.metrics[name]
-	reg.mu.RUnlock()
-	if exists && len(metricsList) > 0 {
-		if counter, ok := metricsList[0].(*Counter); ok {
-			return counter
-		}
+func statusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func mustRegisterShared(reg *MetricRegistry, metric Metric) Metric {
+	err := reg.Register(metric)
+	if err == nil {
+		return metric
 	}
-	counter := NewCounter(name, help, labels)
-	reg.Register(counter)
-	return counter
+	var alreadyRegistered AlreadyRegisteredError
+	if errors.As(err, &alreadyRegistered) {
+		return alreadyRegistered.ExistingMetric
+	}
+	panic(err)
 }
 
-func getOrCreateGauge(reg *MetricRegistry, name, help string, labels map[string]string) *Gauge {
-	reg.mu.RLock()
-	metricsList, exists := reg.metrics[name]
-	reg.mu.RUnlock()
-	if exists && len(metricsList) > 0 {
-		if gauge, ok := metricsList[0].(*Gauge); ok {
-			return gauge
-		}
+func mustGetOrCreateCounter(reg *MetricRegistry, name, help string) *Counter {
+	counter, err := reg.getOrCreateCounter(name, help, nil)
+	if err != nil {
+		panic(err)
 	}
-	gauge := NewGauge(name, help, labels)
-	reg.Register(gauge)
-	return gauge
+	return counter
 }
 
-func getOrCreateHistogram(reg *MetricRegistry, name, help string, buckets []float64, labels map[string]string) *Histogram {
-	reg.mu.RLock()
-	metricsList, exists := reg.metrics[name]
-	reg.mu.RUnlock()
-	if exists && len(metricsList) > 0 {
-		if histogram, ok := metricsList[0].(*Histogram); ok {
-			return histogram
-		}
+func mustGetOrCreateGauge(reg *MetricRegistry, name, help string) *Gauge {
+	gauge, err := reg.getOrCreateGauge(name, help, nil)
+	if err != nil {
+		panic(err)
 	}
-	histogram := NewHistogram(name, help, buckets, labels)
-	reg.Register(histogram)
-	return histogram
+	return gauge
 }
@@ -0,0 +1,220 @@
+// prometheusgin/middleware_options.go
+
+package prometheusgin
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MiddlewareOption func(*Middleware)
+
+func WithSkipPaths(paths ...string) MiddlewareOption {
+	skipped := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		skipped[p] = true
+	}
+	return WithSkipFunc(func(c *gin.Context) bool {
+		return skipped[c.Request.URL.Path]
+	})
+}
+
+func WithSkipRoutes(patterns ...string) MiddlewareOption {
+	return WithSkipFunc(func(c *gin.Context) bool {
+		return matchRoute(patterns, c.FullPath())
+	})
+}
+
+func WithSkipMethods(methods ...string) MiddlewareOption {
+	skipped := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		skipped[strings.ToUpper(method)] = true
+	}
+	return WithSkipFunc(func(c *gin.Context) bool {
+		return skipped[c.Request.Method]
+	})
+}
+
+func WithSkipHeader(name string, match func(value string) bool) MiddlewareOption {
+	return WithSkipFunc(func(c *gin.Context) bool {
+		return match(c.GetHeader(name))
+	})
+}
+
+func WithSkipFunc(skip func(c *gin.Context) bool) MiddlewareOption {
+	return func(m *Middleware) {
+		m.skipRules = append(m.skipRules, skip)
+	}
+}
+
+func WithDurationSampling(rate float64, routes ...string) MiddlewareOption {
+	return func(m *Middleware) {
+		m.sampleRate = rate
+		m.sampledRoutes = routes
+	}
+}
+
+func WithQueueSize(size int) MiddlewareOption {
+	return func(m *Middleware) {
+		m.queueSize = size
+	}
+}
+
+func WithUpdatePolicy(policy UpdatePolicy) MiddlewareOption {
+	return func(m *Middleware) {
+		m.policy = policy
+	}
+}
+
+func WithNamespace(namespace string) MiddlewareOption {
+	return func(m *Middleware) {
+		m.namespace = namespace
+	}
+}
+
+func WithSubsystem(subsystem string) MiddlewareOption {
+	return func(m *Middleware) {
+		m.subsystem = subsystem
+	}
+}
+
+func WithMetricName(metric HTTPMetric, name string) MiddlewareOption {
+	return func(m *Middleware) {
+		if config, ok := m.metrics[metric]; ok {
+			config.name = name
+		}
+	}
+}
+
+func WithMetricHelp(metric HTTPMetric, help string) MiddlewareOption {
+	return func(m *Middleware) {
+		if config, ok := m.metrics[metric]; ok {
+			config.help = help
+		}
+	}
+}
+
+func WithEnabledMetrics(metrics ...HTTPMetric) MiddlewareOption {
+	return func(m *Middleware) {
+		for _, config := range m.metrics {
+			config.enabled = false
+		}
+		for _, metric := range metrics {
+			if config, ok := m.metrics[metric]; ok {
+				config.enabled = true
+			}
+		}
+	}
+}
+
+func WithMetricEnabled(metric HTTPMetric, enabled bool) MiddlewareOption {
+	return func(m *Middleware) {
+		if config, ok := m.metrics[metric]; ok {
+			config.enabled = enabled
+		}
+	}
+}
+
+func WithDurationBuckets(buckets []float64) MiddlewareOption {
+	return func(m *Middleware) {
+		m.durationBuckets = buckets
+	}
+}
+
+func WithRequestSizeBuckets(buckets []float64) MiddlewareOption {
+	return func(m *Middleware) {
+		m.requestBuckets = buckets
+	}
+}
+
+func WithResponseSizeBuckets(buckets []float64) MiddlewareOption {
+	return func(m *Middleware) {
+		m.responseBuckets = buckets
+	}
+}
+
+func WithHistogramOptions(opts ...HistogramOption) MiddlewareOption {
+	return func(m *Middleware) {
+		m.histogramOpts = append(m.histogramOpts, opts...)
+	}
+}
+
+func WithLabels(labels ...string) MiddlewareOption {
+	return func(m *Middleware) {
+		m.labels = labels
+	}
+}
+
+func WithUnmatchedRoute(placeholder string) MiddlewareOption {
+	return func(m *Middleware) {
+		m.unmatchedRoute = placeholder
+	}
+}
+
+func WithLogger(logger Logger) MiddlewareOption {
+	return func(m *Middleware) {
+		m.logger = logger
+	}
+}
+
+func WithLogLevel(level LogLevel) MiddlewareOption {
+	return func(m *Middleware) {
+		m.logLevel = level
+	}
+}
+
+func WithLogFields(fields ...string) MiddlewareOption {
+	return func(m *Middleware) {
+		m.logFields = fields
+	}
+}
+
+func WithCodeMapper(mapper func(status int) string) MiddlewareOption {
+	return func(m *Middleware) {
+		m.codeMapper = mapper
+	}
+}
+
+func WithClassMapper(mapper func(status int) string) MiddlewareOption {
+	return func(m *Middleware) {
+		m.classMapper = mapper
+	}
+}
+
+func WithLabelExtractor(names []string, extract func(c *gin.Context) []string) MiddlewareOption {
+	return func(m *Middleware) {
+		m.extraLabels = names
+		m.labelExtractor = extract
+	}
+}
+
+func WithConstLabels(labels map[string]string) MiddlewareOption {
+	return func(m *Middleware) {
+		m.constLabels = labels
+	}
+}
+
+func WithExemplars() MiddlewareOption {
+	return func(m *Middleware) {
+		m.traceID = TraceIDFromContext
+	}
+}
+
+func WithTraceIDHeader(header string) MiddlewareOption {
+	return func(m *Middleware) {
+		m.traceID = func(c *gin.Context) string {
+			value := c.GetHeader(header)
+			if traceID := traceIDFromTraceparent(value); traceID != "" {
+				return traceID
+			}
+			return value
+		}
+	}
+}
+
+func WithTraceIDExtractor(extract func(c *gin.Context) string) MiddlewareOption {
+	return func(m *Middleware) {
+		m.traceID = extract
+	}
+}
@@ -0,0 +1,216 @@
+package prometheusgin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func requestUpdate(m *Middleware, path string) MetricUpdate {
+	return MetricUpdate{
+		Type:      CounterIncrement,
+		Labels:    map[string]string{LabelMethod: "GET", LabelPath: path, LabelCode: "200"},
+		Value:     1,
+		MetricPtr: m.requests,
+	}
+}
+
+func requestCount(m *Middleware, path string) float64 {
+	m.requests.mu.RLock()
+	defer m.requests.mu.RUnlock()
+	child, ok := m.requests.children[labelValuesKey([]string{"GET", path, "200"})]
+	if !ok {
+		return 0
+	}
+	return child.(*Counter).value()
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// stalledMiddleware returns a middleware with a one-slot queue whose worker is
+// stuck applying the update for "/a" until unstall is called.
+func stalledMiddleware(t *testing.T, policy UpdatePolicy) (m *Middleware, unstall func()) {
+	t.Helper()
+	m = NewMiddleware(NewMetricRegistry(), WithUpdatePolicy(policy), WithQueueSize(1))
+	m.requests.mu.Lock()
+	m.send(requestUpdate(m, "/a"))
+	waitFor(t, "the worker to take the first update", func() bool { return len(m.updates) == 0 })
+	return m, m.requests.mu.Unlock
+}
+
+func TestMiddlewareDropNewest(t *testing.T) {
+	m, unstall := stalledMiddleware(t, UpdateDropNewest)
+	m.send(requestUpdate(m, "/b"))
+	m.send(requestUpdate(m, "/c"))
+	if depth := m.queueDepth.value(); depth != 1 {
+		t.Fatalf("queue depth = %v, want 1", depth)
+	}
+	unstall()
+	m.Close()
+
+	for path, want := range map[string]float64{"/a": 1, "/b": 1, "/c": 0} {
+		if got := requestCount(m, path); got != want {
+			t.Errorf("requests{path=%q} = %v, want %v", path, got, want)
+		}
+	}
+	if dropped := m.dropped.value(); dropped != 1 {
+		t.Errorf("dropped = %v, want 1", dropped)
+	}
+	if depth := m.queueDepth.value(); depth != 0 {
+		t.Errorf("queue depth = %v after Close, want 0", depth)
+	}
+}
+
+func TestMiddlewareDropOldest(t *testing.T) {
+	m, unstall := stalledMiddleware(t, UpdateDropOldest)
+	m.send(requestUpdate(m, "/b"))
+	m.send(requestUpdate(m, "/c"))
+	if depth := m.queueDepth.value(); depth != 1 {
+		t.Fatalf("queue depth = %v, want 1", depth)
+	}
+	unstall()
+	m.Close()
+
+	for path, want := range map[string]float64{"/a": 1, "/b": 0, "/c": 1} {
+		if got := requestCount(m, path); got != want {
+			t.Errorf("requests{path=%q} = %v, want %v", path, got, want)
+		}
+	}
+	if dropped := m.dropped.value(); dropped != 1 {
+		t.Errorf("dropped = %v, want 1", dropped)
+	}
+	if depth := m.queueDepth.value(); depth != 0 {
+		t.Errorf("queue depth = %v after Close, want 0", depth)
+	}
+}
+
+func TestMiddlewareBlock(t *testing.T) {
+	m, unstall := stalledMiddleware(t, UpdateBlock)
+	m.send(requestUpdate(m, "/b"))
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		m.send(requestUpdate(m, "/c"))
+	}()
+
+	select {
+	case <-sent:
+		t.Fatal("send returned while the queue was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if depth := m.queueDepth.value(); depth != 1 {
+		t.Fatalf("queue depth = %v with one blocked sender, want 1", depth)
+	}
+	unstall()
+	<-sent
+	m.Close()
+
+	for _, path := range []string{"/a", "/b", "/c"} {
+		if got := requestCount(m, path); got != 1 {
+			t.Errorf("requests{path=%q} = %v, want 1", path, got)
+		}
+	}
+	if dropped := m.dropped.value(); dropped != 0 {
+		t.Errorf("dropped = %v, want 0", dropped)
+	}
+	if depth := m.queueDepth.value(); depth != 0 {
+		t.Errorf("queue depth = %v after Close, want 0", depth)
+	}
+}
+
+func TestMiddlewareSynchronous(t *testing.T) {
+	reg := NewMetricRegistry()
+	m := NewMiddleware(reg, WithUpdatePolicy(UpdateSynchronous))
+	if m.updates != nil {
+		t.Fatal("synchronous middleware started a worker queue")
+	}
+	m.send(requestUpdate(m, "/a"))
+	if got := requestCount(m, "/a"); got != 1 {
+		t.Fatalf("requests = %v right after send, want 1", got)
+	}
+	if len(reg.closers) != 0 {
+		t.Fatalf("synchronous middleware registered %d closers, want 0", len(reg.closers))
+	}
+	m.Close()
+}
+
+func TestMiddlewareCloseDrainsQueue(t *testing.T) {
+	reg := NewMetricRegistry()
+	m := NewMiddleware(reg, WithQueueSize(100))
+	m.requests.mu.Lock()
+	for i := 0; i < 50; i++ {
+		m.send(requestUpdate(m, "/a"))
+	}
+	m.requests.mu.Unlock()
+	m.Close()
+
+	select {
+	case <-m.done:
+	default:
+		t.Fatal("worker still running after Close")
+	}
+	if got := requestCount(m, "/a"); got != 50 {
+		t.Fatalf("requests = %v after Close, want 50", got)
+	}
+	if len(reg.closers) != 0 {
+		t.Fatalf("registry still holds %d closers after Middleware.Close", len(reg.closers))
+	}
+
+	m.send(requestUpdate(m, "/a"))
+	if got := requestCount(m, "/a"); got != 51 {
+		t.Fatalf("requests = %v after sending on a closed middleware, want 51", got)
+	}
+}
+
+func TestRegistryCloseStopsMiddlewareWorkers(t *testing.T) {
+	reg := NewMetricRegistry()
+	first := NewMiddleware(reg)
+	second := NewMiddleware(reg, WithUpdatePolicy(UpdateDropOldest))
+	reg.Close()
+
+	for _, m := range []*Middleware{first, second} {
+		select {
+		case <-m.done:
+		default:
+			t.Fatal("worker still running after registry Close")
+		}
+	}
+}
+
+func TestMiddlewareSkipsRequestsWithMismatchedExtraLabels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reg := NewMetricRegistry()
+	m := NewMiddleware(reg, WithUpdatePolicy(UpdateSynchronous), WithLabelExtractor([]string{"tenant"}, func(c *gin.Context) []string {
+		if c.Query("bad") != "" {
+			return []string{"a", "b"}
+		}
+		return []string{c.Query("tenant")}
+	}))
+	engine := gin.New()
+	engine.Use(m.Handler())
+	engine.GET("/x", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, target := range []string{"/x?tenant=t1", "/x?bad=1", "/x?tenant=t1"} {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	samples := m.requests.Snapshot().Samples
+	if len(samples) != 1 || samples[0].Value != 2 {
+		t.Fatalf("got request series %+v, want only tenant t1 with 2 requests", samples)
+	}
+	if got := m.labelErrors.value(); got != 1 {
+		t.Fatalf("label extraction errors = %v, want 1", got)
+	}
+}
@@ -0,0 +1,104 @@
+// prometheusgin/native.go
+
+package prometheusgin
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	DefaultNativeHistogramSchema        int32 = 3
+	DefaultNativeHistogramZeroThreshold       = 2.938735877055719e-39
+	DefaultNativeHistogramMaxBuckets          = 160
+
+	minNativeHistogramSchema int32 = -4
+	maxNativeHistogramSchema int32 = 8
+)
+
+var nativeHistogramBounds = func() [][]float64 {
+	bounds := make([][]float64, maxNativeHistogramSchema+1)
+	for schema := range bounds {
+		n := 1 << schema
+		bounds[schema] = make([]float64, n)
+		for i := 0; i < n; i++ {
+			bounds[schema][i] = math.Exp2(float64(i)/float64(n) - 1)
+		}
+	}
+	return bounds
+}()
+
+func clampNativeSchema(schema int32) int32 {
+	if schema < minNativeHistogramSchema {
+		return minNativeHistogramSchema
+	}
+	if schema > maxNativeHistogramSchema {
+		return maxNativeHistogramSchema
+	}
+	return schema
+}
+
+func nativeBucketIndex(v float64, schema int32) int {
+	if math.IsInf(v, 1) {
+		return math.MaxInt32
+	}
+	frac, exp := math.Frexp(v)
+	if schema > 0 {
+		bounds := nativeHistogramBounds[schema]
+		return sort.SearchFloat64s(bounds, frac) + (exp-1)*len(bounds)
+	}
+	key := exp
+	if frac == 0.5 {
+		key--
+	}
+	offset := (1 << -schema) - 1
+	return (key + offset) >> -schema
+}
+
+func reduceNativeBucketIndex(key int, from, to int32) int {
+	for ; from > to; from-- {
+		key = (key + 1) >> 1
+	}
+	return key
+}
+
+func addNativeBucket(buckets *sync.Map, key int, n uint64, created *atomic.Uint32) {
+	if n == 0 {
+		return
+	}
+	if counter, ok := buckets.Load(key); ok {
+		counter.(*atomic.Uint64).Add(n)
+		return
+	}
+	counter, loaded := buckets.LoadOrStore(key, new(atomic.Uint64))
+	if !loaded {
+		created.Add(1)
+	}
+	counter.(*atomic.Uint64).Add(n)
+}
+
+func drainNativeBuckets(buckets *sync.Map) map[int]uint64 {
+	drained := make(map[int]uint64)
+	buckets.Range(func(key, counter any) bool {
+		drained[key.(int)] += counter.(*atomic.Uint64).Swap(0)
+		buckets.Delete(key)
+		return true
+	})
+	return drained
+}
+
+func readNativeBuckets(buckets *sync.Map) []NativeBucket {
+	var out []NativeBucket
+	buckets.Range(func(key, counter any) bool {
+		if n := counter.(*atomic.Uint64).Load(); n > 0 {
+			out = append(out, NativeBucket{Index: int32(key.(int)), Count: n})
+		}
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Index < out[j].Index
+	})
+	return out
+}
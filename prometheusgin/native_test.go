@@ -0,0 +1,151 @@
+package prometheusgin
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestNativeBucketIndex(t *testing.T) {
+	// Expected indexes match client_golang's native histogram bucketing.
+	tests := []struct {
+		schema int32
+		value  float64
+		want   int
+	}{
+		{0, 0.25, -2},
+		{0, 1, 0},
+		{0, 1.5, 1},
+		{0, 2, 1},
+		{0, 3, 2},
+		{0, 16, 4},
+		{0, 17, 5},
+		{3, 0.25, -16},
+		{3, 1, 0},
+		{3, 1.5, 5},
+		{3, 2, 8},
+		{3, 16, 32},
+		{3, 17, 33},
+		{-2, 0.25, 0},
+		{-2, 1, 0},
+		{-2, 1.5, 1},
+		{-2, 16, 1},
+		{-2, 17, 2},
+		{8, math.Inf(1), math.MaxInt32},
+	}
+	for _, tt := range tests {
+		if got := nativeBucketIndex(tt.value, tt.schema); got != tt.want {
+			t.Errorf("nativeBucketIndex(%v, %d) = %d, want %d", tt.value, tt.schema, got, tt.want)
+		}
+	}
+}
+
+func TestNativeBucketIndexMatchesLog2(t *testing.T) {
+	for schema := minNativeHistogramSchema; schema <= maxNativeHistogramSchema; schema++ {
+		for _, v := range []float64{0.0007, 0.3, 0.7, 1.3, 3.3, 42.42, 1000.1, 12345.678} {
+			want := int(math.Ceil(math.Log2(v) * math.Exp2(float64(schema))))
+			if got := nativeBucketIndex(v, schema); got != want {
+				t.Errorf("nativeBucketIndex(%v, %d) = %d, want %d", v, schema, got, want)
+			}
+		}
+	}
+}
+
+func TestReduceNativeBucketIndex(t *testing.T) {
+	values := []float64{0.0007, 0.25, 0.3, 1, 1.3, 1.5, 2, 3, 16, 17, 42.42, 1000.1, 1 << 20}
+	for from := maxNativeHistogramSchema; from >= minNativeHistogramSchema; from-- {
+		for to := from; to >= minNativeHistogramSchema; to-- {
+			for _, v := range values {
+				got := reduceNativeBucketIndex(nativeBucketIndex(v, from), from, to)
+				if want := nativeBucketIndex(v, to); got != want {
+					t.Errorf("reducing index of %v from schema %d to %d = %d, want %d", v, from, to, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestHistogramLimitNativeBuckets(t *testing.T) {
+	const maxBuckets, workers = 10, 4
+	h := NewHistogram("test_seconds", "Test histogram.", nil, nil, WithNativeHistogram(DefaultNativeHistogramSchema), WithNativeHistogramMaxBuckets(maxBuckets))
+
+	var values []float64
+	for i := -100; i <= 200; i++ {
+		v := math.Pow(1.07, float64(i))
+		values = append(values, v, -v)
+	}
+	values = append(values, 0)
+
+	stop := make(chan struct{})
+	scraped := make(chan struct{})
+	go func() {
+		defer close(scraped)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			sample := h.Snapshot().Samples[0]
+			if total := nativeTotal(sample.Native); total != sample.Count {
+				t.Errorf("native buckets hold %d observations, count is %d", total, sample.Count)
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, v := range values {
+				h.Observe(v)
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-scraped
+
+	sample := h.Snapshot().Samples[0]
+	native := sample.Native
+	if native.Schema >= DefaultNativeHistogramSchema {
+		t.Fatalf("schema = %d, want it reduced below %d", native.Schema, DefaultNativeHistogramSchema)
+	}
+	if n := len(native.Positive) + len(native.Negative); n > maxBuckets {
+		t.Fatalf("got %d buckets, want at most %d", n, maxBuckets)
+	}
+	if sample.Count != uint64(workers*len(values)) {
+		t.Fatalf("count = %d, want %d", sample.Count, workers*len(values))
+	}
+	if total := nativeTotal(native); total != sample.Count {
+		t.Fatalf("native buckets hold %d observations, count is %d", total, sample.Count)
+	}
+
+	want := map[int32]uint64{}
+	for _, v := range values {
+		if v > 0 {
+			want[int32(nativeBucketIndex(v, native.Schema))] += workers
+		}
+	}
+	for _, b := range native.Positive {
+		if b.Count != want[b.Index] {
+			t.Errorf("positive bucket %d = %d, want %d", b.Index, b.Count, want[b.Index])
+		}
+	}
+	if native.ZeroCount != workers {
+		t.Errorf("zero count = %d, want %d", native.ZeroCount, workers)
+	}
+}
+
+func nativeTotal(native *NativeHistogram) uint64 {
+	total := native.ZeroCount
+	for _, b := range native.Positive {
+		total += b.Count
+	}
+	for _, b := range native.Negative {
+		total += b.Count
+	}
+	return total
+}
@@ -0,0 +1,90 @@
+// prometheusgin/openmetrics.go
+
+package prometheusgin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func openMetricsFamilyName(name string, metricType FamilyType) string {
+	switch metricType {
+	case TypeCounter:
+		return strings.TrimSuffix(name, "_total")
+	case TypeInfo:
+		return strings.TrimSuffix(name, "_info")
+	default:
+		return name
+	}
+}
+
+func openMetricsType(metricType FamilyType) string {
+	if metricType == TypeUntyped {
+		return "unknown"
+	}
+	return string(metricType)
+}
+
+func writeOpenMetrics(sb *strings.Builder, snapshot MetricSnapshot) {
+	family := openMetricsFamilyName(snapshot.Name, snapshot.Type)
+	sb.WriteString(fmt.Sprintf("# TYPE %s %s\n", family, openMetricsType(snapshot.Type)))
+	if snapshot.Unit != "" {
+		sb.WriteString(fmt.Sprintf("# UNIT %s %s\n", family, snapshot.Unit))
+	}
+	sb.WriteString(fmt.Sprintf("# HELP %s %s\n", family, escapeOpenMetricsHelp(snapshot.Help)))
+	for _, sample := range snapshot.Samples {
+		labels := formatLabelPairs(sample.Labels)
+		timestamp := openMetricsTimestamp(sample.Timestamp)
+		switch snapshot.Type {
+		case TypeCounter:
+			writeOpenMetricsSample(sb, family+"_total", labels, formatFloat(sample.Value), timestamp, sample.Exemplar)
+		case TypeInfo:
+			writeSample(sb, family+"_info", labels, formatFloat(sample.Value), timestamp)
+		case TypeHistogram:
+			for _, b := range sample.Buckets {
+				writeOpenMetricsSample(sb, family+"_bucket", formatLabelPairs(sample.Labels, "le", formatFloat(b.UpperBound)), strconv.FormatUint(b.CumulativeCount, 10), timestamp, b.Exemplar)
+			}
+			writeOpenMetricsSample(sb, family+"_bucket", formatLabelPairs(sample.Labels, "le", "+Inf"), strconv.FormatUint(sample.Count, 10), timestamp, sample.Exemplar)
+			writeSample(sb, family+"_count", labels, strconv.FormatUint(sample.Count, 10), timestamp)
+			writeSample(sb, family+"_sum", labels, formatFloat(sample.Sum), timestamp)
+		case TypeSummary:
+			for _, q := range sample.Quantiles {
+				writeSample(sb, family, formatLabelPairs(sample.Labels, "quantile", formatFloat(q.Quantile)), formatFloat(q.Value), timestamp)
+			}
+			writeSample(sb, family+"_count", labels, strconv.FormatUint(sample.Count, 10), timestamp)
+			writeSample(sb, family+"_sum", labels, formatFloat(sample.Sum), timestamp)
+		default:
+			writeSample(sb, family, labels, formatFloat(sample.Value), timestamp)
+		}
+		if !sample.Created.IsZero() {
+			writeSample(sb, family+"_created", labels, openMetricsTimestamp(sample.Created), "")
+		}
+	}
+}
+
+func writeOpenMetricsSample(sb *strings.Builder, name, labelString, value, timestamp string, exemplar *Exemplar) {
+	sb.WriteString(name)
+	if labelString != "" {
+		sb.WriteString("{" + labelString + "}")
+	}
+	sb.WriteString(" " + value)
+	if timestamp != "" {
+		sb.WriteString(" " + timestamp)
+	}
+	if exemplar != nil {
+		sb.WriteString(" # {" + formatLabelPairs(exemplar.Labels) + "} " + formatFloat(exemplar.Value))
+		if !exemplar.Timestamp.IsZero() {
+			sb.WriteString(" " + openMetricsTimestamp(exemplar.Timestamp))
+		}
+	}
+	sb.WriteString("\n")
+}
+
+func openMetricsTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return formatFloat(float64(t.UnixNano()) / 1e9)
+}
@@ -3,12 +3,15 @@
 package prometheusgin
 
 import (
+	"errors"
+
 	"github.com/gin-gonic/gin"
 )
 
 type PrometheusGin struct {
-	registry *MetricRegistry
-	engine   *gin.Engine
+	registry   *MetricRegistry
+	engine     *gin.Engine
+	middleware *Middleware
 }
 
 func NewPrometheusGin() *PrometheusGin {
@@ -20,50 +23,69 @@ func NewPrometheusGin() *PrometheusGin {
 	}
 }
 
-func (pg *PrometheusGin) UseMetricsMiddleware() {
-	pg.engine.Use(PrometheusMiddleware(pg.registry))
+func (pg *PrometheusGin) UseMetricsMiddleware(opts ...MiddlewareOption) {
+	pg.middleware = NewMiddleware(pg.registry, opts...)
+	pg.engine.Use(pg.middleware.Handler())
+}
+
+func (pg *PrometheusGin) register(metric Metric) Metric {
+	err := pg.registry.Register(metric)
+	if err == nil {
+		return metric
+	}
+	var alreadyRegistered AlreadyRegisteredError
+	if errors.As(err, &alreadyRegistered) {
+		return alreadyRegistered.ExistingMetric
+	}
+	panic(err)
 }
 
 func (pg *PrometheusGin) RegisterCounter(name, help string, labels map[string]string) *Counter {
-	counter := NewCounter(name, help, labels)
-	pg.registry.Register(counter)
-	return counter
+	return pg.register(NewCounter(name, help, labels)).(*Counter)
 }
 
 func (pg *PrometheusGin) RegisterGauge(name, help string, labels map[string]string) *Gauge {
-	gauge := NewGauge(name, help, labels)
-	pg.registry.Register(gauge)
-	return gauge
+	return pg.register(NewGauge(name, help, labels)).(*Gauge)
 }
 
-func (pg *PrometheusGin) RegisterHistogram(name, help string, buckets []float64, labels map[string]string) *Histogram {
-	histogram := NewHistogram(name, help, buckets, labels)
-	pg.registry.Register(histogram)
-	return histogram
+func (pg *PrometheusGin) RegisterHistogram(name, help string, buckets []float64, labels map[string]string, opts ...HistogramOption) *Histogram {
+	return pg.register(NewHistogram(name, help, buckets, labels, opts...)).(*Histogram)
 }
 
-func (pg *PrometheusGin) RegisterSummary(name, help string, quantiles []float64, labels map[string]string) *Summary {
-	summary := NewSummary(name, help, quantiles, labels)
-	pg.registry.Register(summary)
-	return summary
+func (pg *PrometheusGin) RegisterSummary(name, help string, quantiles []float64, labels map[string]string, opts ...SummaryOption) *Summary {
+	return pg.register(NewSummary(name, help, quantiles, labels, opts...)).(*Summary)
 }
 
-func (pg *PrometheusGin) RegisterInfo(name, help string, info string, labels map[string]string) *Info {
-	infoMetric := NewInfo(name, help, info, labels)
-	pg.registry.Register(infoMetric)
-	return infoMetric
+func (pg *PrometheusGin) RegisterInfo(name, help string, info map[string]string, labels map[string]string) *Info {
+	return pg.register(NewInfo(name, help, info, labels)).(*Info)
 }
 
-func (pg *PrometheusGin) RegisterStateset(name, help string, state string, labels map[string]string) *Stateset {
-	stateset := NewStateset(name, help, state, labels)
-	pg.registry.Register(stateset)
-	return stateset
+func (pg *PrometheusGin) RegisterBuildInfo(name, help string) *Info {
+	return pg.register(NewBuildInfo(name, help)).(*Info)
+}
+
+func (pg *PrometheusGin) RegisterStateset(name, help string, states []string, labels map[string]string) *Stateset {
+	return pg.register(NewStateset(name, help, states, labels)).(statesetProvider).stateset()
 }
 
 func (pg *PrometheusGin) RegisterUntyped(name, help string, labels map[string]string) *Untyped {
-	untyped := NewUntyped(name, help, labels)
-	pg.registry.Register(untyped)
-	return untyped
+	return pg.register(NewUntyped(name, help, labels)).(*Untyped)
+}
+
+func (pg *PrometheusGin) RegisterCounterVec(name, help string, labelNames []string) *CounterVec {
+	return pg.register(NewCounterVec(name, help, labelNames)).(*CounterVec)
+}
+
+func (pg *PrometheusGin) RegisterGaugeVec(name, help string, labelNames []string) *GaugeVec {
+	return pg.register(NewGaugeVec(name, help, labelNames)).(*GaugeVec)
+}
+
+func (pg *PrometheusGin) RegisterHistogramVec(name, help string, buckets []float64, labelNames []string, opts ...HistogramOption) *HistogramVec {
+	return pg.register(NewHistogramVec(name, help, buckets, labelNames, opts...)).(*HistogramVec)
+}
+
+func (pg *PrometheusGin) RegisterSummaryVec(name, help string, quantiles []float64, labelNames []string, opts ...SummaryOption) *SummaryVec {
+	return pg.register(NewSummaryVec(name, help, quantiles, labelNames, opts...)).(*SummaryVec)
 }
 
 func (pg *PrometheusGin) MetricsHandler(path string) {
@@ -80,3 +102,10 @@ func (pg *PrometheusGin) Registry() *MetricRegistry {
 func (pg *PrometheusGin) Run(addr string) error {
 	return pg.engine.Run(addr)
 }
+
+func (pg *PrometheusGin) Close() {
+	if pg.middleware != nil {
+		pg.middleware.Close()
+	}
+	pg.registry.Close()
+}
@@ -0,0 +1,168 @@
+// prometheusgin/protobuf.go
+
+package prometheusgin
+
+import (
+	"bytes"
+	"math"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func protobufMetricType(metricType FamilyType) dto.MetricType {
+	switch metricType {
+	case TypeCounter:
+		return dto.MetricType_COUNTER
+	case TypeGauge, TypeInfo, TypeStateset:
+		return dto.MetricType_GAUGE
+	case TypeHistogram:
+		return dto.MetricType_HISTOGRAM
+	case TypeSummary:
+		return dto.MetricType_SUMMARY
+	default:
+		return dto.MetricType_UNTYPED
+	}
+}
+
+func protobufFamily(snapshot MetricSnapshot) *dto.MetricFamily {
+	family := &dto.MetricFamily{
+		Name: proto.String(textFamilyName(snapshot)),
+		Help: proto.String(snapshot.Help),
+		Type: protobufMetricType(snapshot.Type).Enum(),
+	}
+	if snapshot.Unit != "" {
+		family.Unit = proto.String(snapshot.Unit)
+	}
+	for _, sample := range snapshot.Samples {
+		family.Metric = append(family.Metric, protobufMetric(snapshot.Type, sample))
+	}
+	return family
+}
+
+func protobufMetric(metricType FamilyType, sample Sample) *dto.Metric {
+	metric := &dto.Metric{Label: protobufLabelPairs(sample.Labels)}
+	if !sample.Timestamp.IsZero() {
+		metric.TimestampMs = proto.Int64(sample.Timestamp.UnixMilli())
+	}
+	var created *timestamppb.Timestamp
+	if !sample.Created.IsZero() {
+		created = timestamppb.New(sample.Created)
+	}
+	switch metricType {
+	case TypeCounter:
+		metric.Counter = &dto.Counter{Value: proto.Float64(sample.Value), Exemplar: protobufExemplar(sample.Exemplar), CreatedTimestamp: created}
+	case TypeGauge, TypeInfo, TypeStateset:
+		metric.Gauge = &dto.Gauge{Value: proto.Float64(sample.Value)}
+	case TypeHistogram:
+		histogram := &dto.Histogram{
+			SampleCount:      proto.Uint64(sample.Count),
+			SampleSum:        proto.Float64(sample.Sum),
+			CreatedTimestamp: created,
+		}
+		for _, b := range sample.Buckets {
+			histogram.Bucket = append(histogram.Bucket, &dto.Bucket{
+				CumulativeCount: proto.Uint64(b.CumulativeCount),
+				UpperBound:      proto.Float64(b.UpperBound),
+				Exemplar:        protobufExemplar(b.Exemplar),
+			})
+		}
+		if sample.Exemplar != nil && (sample.Native == nil || len(sample.Buckets) > 0) {
+			histogram.Bucket = append(histogram.Bucket, &dto.Bucket{
+				CumulativeCount: proto.Uint64(sample.Count),
+				UpperBound:      proto.Float64(math.Inf(1)),
+				Exemplar:        protobufExemplar(sample.Exemplar),
+			})
+		}
+		if native := sample.Native; native != nil {
+			histogram.Schema = proto.Int32(native.Schema)
+			histogram.ZeroThreshold = proto.Float64(native.ZeroThreshold)
+			histogram.ZeroCount = proto.Uint64(native.ZeroCount)
+			histogram.PositiveSpan, histogram.PositiveDelta = protobufNativeBuckets(native.Positive)
+			histogram.NegativeSpan, histogram.NegativeDelta = protobufNativeBuckets(native.Negative)
+			for _, b := range sample.Buckets {
+				if b.Exemplar != nil {
+					histogram.Exemplars = append(histogram.Exemplars, protobufExemplar(b.Exemplar))
+				}
+			}
+			if sample.Exemplar != nil {
+				histogram.Exemplars = append(histogram.Exemplars, protobufExemplar(sample.Exemplar))
+			}
+			if len(histogram.PositiveSpan) == 0 && len(histogram.NegativeSpan) == 0 && native.ZeroCount == 0 {
+				histogram.PositiveSpan = []*dto.BucketSpan{{Offset: proto.Int32(0), Length: proto.Uint32(0)}}
+			}
+		}
+		metric.Histogram = histogram
+	case TypeSummary:
+		summary := &dto.Summary{
+			SampleCount:      proto.Uint64(sample.Count),
+			SampleSum:        proto.Float64(sample.Sum),
+			CreatedTimestamp: created,
+		}
+		for _, q := range sample.Quantiles {
+			summary.Quantile = append(summary.Quantile, &dto.Quantile{
+				Quantile: proto.Float64(q.Quantile),
+				Value:    proto.Float64(q.Value),
+			})
+		}
+		metric.Summary = summary
+	default:
+		metric.Untyped = &dto.Untyped{Value: proto.Float64(sample.Value)}
+	}
+	return metric
+}
+
+func protobufNativeBuckets(buckets []NativeBucket) ([]*dto.BucketSpan, []int64) {
+	var spans []*dto.BucketSpan
+	var deltas []int64
+	var prevIndex int32
+	var prevCount int64
+	for i, b := range buckets {
+		if i == 0 || b.Index != prevIndex+1 {
+			offset := b.Index
+			if i > 0 {
+				offset = b.Index - prevIndex - 1
+			}
+			spans = append(spans, &dto.BucketSpan{Offset: proto.Int32(offset), Length: proto.Uint32(0)})
+		}
+		*spans[len(spans)-1].Length++
+		deltas = append(deltas, int64(b.Count)-prevCount)
+		prevIndex = b.Index
+		prevCount = int64(b.Count)
+	}
+	return spans, deltas
+}
+
+func protobufExemplar(exemplar *Exemplar) *dto.Exemplar {
+	if exemplar == nil {
+		return nil
+	}
+	e := &dto.Exemplar{
+		Label: protobufLabelPairs(exemplar.Labels),
+		Value: proto.Float64(exemplar.Value),
+	}
+	if !exemplar.Timestamp.IsZero() {
+		e.Timestamp = timestamppb.New(exemplar.Timestamp)
+	}
+	return e
+}
+
+func protobufLabelPairs(labels []LabelPair) []*dto.LabelPair {
+	pairs := make([]*dto.LabelPair, 0, len(labels))
+	for _, label := range labels {
+		pairs = append(pairs, &dto.LabelPair{Name: proto.String(label.Name), Value: proto.String(label.Value)})
+	}
+	return pairs
+}
+
+func encodeDelimited(families []*dto.MetricFamily) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, family := range families {
+		if _, err := protodelim.MarshalTo(&buf, family); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
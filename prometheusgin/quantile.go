@@ -0,0 +1,142 @@
+// prometheusgin/quantile.go
+
+package prometheusgin
+
+import (
+	"math"
+	"sort"
+)
+
+const quantileBufferSize = 500
+
+type quantileTarget struct {
+	quantile float64
+	epsilon  float64
+}
+
+type quantileSample struct {
+	value float64
+	width float64
+	delta float64
+}
+
+type quantileStream struct {
+	targets []quantileTarget
+	samples []quantileSample
+	buffer  []float64
+	n       float64
+}
+
+func newQuantileStream(targets []quantileTarget) *quantileStream {
+	return &quantileStream{
+		targets: targets,
+		buffer:  make([]float64, 0, quantileBufferSize),
+	}
+}
+
+func (s *quantileStream) insert(v float64) {
+	s.buffer = append(s.buffer, v)
+	if len(s.buffer) == cap(s.buffer) {
+		s.flush()
+	}
+}
+
+func (s *quantileStream) reset() {
+	s.samples = s.samples[:0]
+	s.buffer = s.buffer[:0]
+	s.n = 0
+}
+
+func (s *quantileStream) query(q float64) float64 {
+	s.flush()
+	if len(s.samples) == 0 {
+		return math.NaN()
+	}
+	t := math.Ceil(q * s.n)
+	t += math.Ceil(s.invariant(t) / 2)
+	prev := s.samples[0]
+	var r float64
+	for _, c := range s.samples[1:] {
+		r += prev.width
+		if r+c.width+c.delta > t {
+			return prev.value
+		}
+		prev = c
+	}
+	return prev.value
+}
+
+func (s *quantileStream) invariant(r float64) float64 {
+	m := math.MaxFloat64
+	for _, t := range s.targets {
+		var f float64
+		if t.quantile*s.n <= r {
+			f = (2 * t.epsilon * r) / t.quantile
+		} else {
+			f = (2 * t.epsilon * (s.n - r)) / (1 - t.quantile)
+		}
+		if f < m {
+			m = f
+		}
+	}
+	return m
+}
+
+func (s *quantileStream) flush() {
+	if len(s.buffer) == 0 {
+		return
+	}
+	sort.Float64s(s.buffer)
+	s.merge(s.buffer)
+	s.buffer = s.buffer[:0]
+	s.compress()
+}
+
+func (s *quantileStream) merge(values []float64) {
+	var r float64
+	i := 0
+	for _, v := range values {
+		inserted := false
+		for ; i < len(s.samples); i++ {
+			c := s.samples[i]
+			if c.value > v {
+				delta := math.Max(0, math.Floor(s.invariant(r))-1)
+				s.samples = append(s.samples, quantileSample{})
+				copy(s.samples[i+1:], s.samples[i:])
+				s.samples[i] = quantileSample{value: v, width: 1, delta: delta}
+				i++
+				inserted = true
+				break
+			}
+			r += c.width
+		}
+		if !inserted {
+			s.samples = append(s.samples, quantileSample{value: v, width: 1})
+			i++
+		}
+		s.n++
+		r++
+	}
+}
+
+func (s *quantileStream) compress() {
+	if len(s.samples) < 2 {
+		return
+	}
+	x := s.samples[len(s.samples)-1]
+	xi := len(s.samples) - 1
+	r := s.n - 1 - x.width
+	for i := len(s.samples) - 2; i >= 0; i-- {
+		c := s.samples[i]
+		if c.width+x.width+x.delta <= s.invariant(r) {
+			x.width += c.width
+			s.samples[xi] = x
+			s.samples = append(s.samples[:i], s.samples[i+1:]...)
+			xi--
+		} else {
+			x = c
+			xi = i
+		}
+		r -= c.width
+	}
+}
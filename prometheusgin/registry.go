@@ -3,58 +3,332 @@
 package prometheusgin
 
 import (
+	"fmt"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
 )
 
 type MetricRegistry struct {
-	metrics map[string][]Metric
-	mu      sync.RWMutex
+	metrics   map[string][]Metric
+	series    map[string]Metric
+	seriesTTL time.Duration
+	guard     *cardinalityGuard
+	closers   map[uint64]func()
+	closerID  uint64
+	mu        sync.RWMutex
+}
+
+type RegistryOption func(*MetricRegistry)
+
+func WithSeriesTTL(ttl time.Duration) RegistryOption {
+	return func(r *MetricRegistry) {
+		r.seriesTTL = ttl
+	}
+}
+
+func WithMaxSeriesPerFamily(limit int) RegistryOption {
+	return func(r *MetricRegistry) {
+		r.guard = &cardinalityGuard{limit: limit, rejectedSets: make(map[uint64]struct{})}
+	}
+}
+
+type updateTracker interface {
+	updatedAt() time.Time
 }
 
-func NewMetricRegistry() *MetricRegistry {
-	return &MetricRegistry{
+type staleExpirer interface {
+	expire(deadline time.Time)
+}
+
+type seriesDeleter interface {
+	Delete(labels map[string]string) bool
+}
+
+func NewMetricRegistry(opts ...RegistryOption) *MetricRegistry {
+	r := &MetricRegistry{
 		metrics: make(map[string][]Metric),
+		series:  make(map[string]Metric),
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	if r.guard != nil {
+		r.guard.rejected = NewCounterVec("prometheusgin_rejected_series_total", "Label sets rejected because their metric family reached the series limit.", []string{"metric"})
+		r.MustRegister(r.guard.rejected)
+	}
+	return r
 }
 
-func (r *MetricRegistry) Register(metric Metric) {
+func (r *MetricRegistry) Register(metric Metric) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.metrics[getMetricName(metric)] = append(r.metrics[getMetricName(metric)], metric)
+	desc := describeMetric(metric)
+	if _, exists := r.series[seriesKey(desc.name, desc.labelString)]; !exists && !desc.isVec && !r.guard.allow(desc.name, desc.labelString, len(r.metrics[desc.name])) {
+		return fmt.Errorf("metric %q reached the limit of %d series", desc.name, r.guard.limit)
+	}
+	return r.register(metric)
 }
 
-func (r *MetricRegistry) ExportAll() string {
+func (r *MetricRegistry) onClose(closer func()) (remove func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closers == nil {
+		r.closers = make(map[uint64]func())
+	}
+	r.closerID++
+	id := r.closerID
+	r.closers[id] = closer
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.closers, id)
+	}
+}
+
+func (r *MetricRegistry) Close() {
+	r.mu.Lock()
+	closers := r.closers
+	r.closers = nil
+	r.mu.Unlock()
+	for _, closer := range closers {
+		closer()
+	}
+}
+
+func (r *MetricRegistry) MustRegister(metrics ...Metric) {
+	for _, metric := range metrics {
+		if err := r.Register(metric); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (r *MetricRegistry) register(metric Metric) error {
+	desc := describeMetric(metric)
+	if err := desc.validate(); err != nil {
+		return err
+	}
+	if metricsList := r.metrics[desc.name]; len(metricsList) > 0 {
+		existing := metricsList[0]
+		existingDesc := describeMetric(existing)
+		if existingDesc.metricType != desc.metricType {
+			return fmt.Errorf("metric %q is already registered as %s, cannot register it as %s", desc.name, existingDesc.metricType, desc.metricType)
+		}
+		if !existingDesc.sameLabelNames(desc) {
+			return fmt.Errorf("metric %q is already registered with label names %v, cannot register it with %v", desc.name, existingDesc.labelNames, desc.labelNames)
+		}
+		if existingDesc.help != desc.help {
+			return fmt.Errorf("metric %q is already registered with help %q, cannot register it with %q", desc.name, existingDesc.help, desc.help)
+		}
+		if !existingDesc.sameStates(desc) {
+			return fmt.Errorf("stateset %q is already registered with states %v, cannot register it with %v", desc.name, existingDesc.states, desc.states)
+		}
+		if existingDesc.isVec || desc.isVec {
+			if reflect.TypeOf(existing) == reflect.TypeOf(metric) {
+				return AlreadyRegisteredError{ExistingMetric: existing, NewMetric: metric}
+			}
+			return fmt.Errorf("metric %q is already registered with a conflicting collector", desc.name)
+		}
+	}
+	key := seriesKey(desc.name, desc.labelString)
+	if existing, exists := r.series[key]; exists {
+		return AlreadyRegisteredError{ExistingMetric: existing, NewMetric: metric}
+	}
+	if limited, ok := metric.(cardinalityLimited); ok && r.guard != nil && metric != Metric(r.guard.rejected) {
+		limited.setCardinalityGuard(r.guard)
+	}
+	r.metrics[desc.name] = append(r.metrics[desc.name], metric)
+	if !desc.isVec {
+		r.series[key] = metric
+	}
+	return nil
+}
+
+func (r *MetricRegistry) Unregister(metric Metric) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	desc := describeMetric(metric)
+	for i, existing := range r.metrics[desc.name] {
+		if existing == metric {
+			r.remove(desc.name, i)
+			return true
+		}
+	}
+	return false
+}
+
+func (r *MetricRegistry) UnregisterByName(name string, labels map[string]string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, exists := r.series[seriesKey(name, formatLabels(labels))]; exists {
+		for i, metric := range r.metrics[name] {
+			if metric == existing {
+				r.remove(name, i)
+				return true
+			}
+		}
+	}
+	for _, metric := range r.metrics[name] {
+		if vec, ok := metric.(seriesDeleter); ok && vec.Delete(labels) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *MetricRegistry) remove(name string, index int) {
+	metricsList := r.metrics[name]
+	desc := describeMetric(metricsList[index])
+	if !desc.isVec {
+		delete(r.series, seriesKey(name, desc.labelString))
+	}
+	metricsList = append(metricsList[:index:index], metricsList[index+1:]...)
+	if len(metricsList) == 0 {
+		delete(r.metrics, name)
+		return
+	}
+	r.metrics[name] = metricsList
+}
+
+func (r *MetricRegistry) expireStale() {
+	if r.seriesTTL <= 0 {
+		return
+	}
+	deadline := time.Now().Add(-r.seriesTTL)
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	var sb strings.Builder
 	for _, metricsList := range r.metrics {
 		for _, metric := range metricsList {
-			sb.WriteString(metric.Export())
+			if expirer, ok := metric.(staleExpirer); ok {
+				expirer.expire(deadline)
+			}
 		}
 	}
+}
+
+func (r *MetricRegistry) Lookup(name string, labels map[string]string) Metric {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.series[seriesKey(name, formatLabels(labels))]
+}
+
+func seriesKey(name, labelString string) string {
+	return name + "{" + labelString + "}"
+}
+
+func (r *MetricRegistry) ExportAll() string {
+	var sb strings.Builder
+	for _, snapshot := range r.Snapshot() {
+		writeText(&sb, snapshot)
+	}
+	return sb.String()
+}
+
+func (r *MetricRegistry) ExportAllOpenMetrics() string {
+	var sb strings.Builder
+	for _, snapshot := range r.Snapshot() {
+		writeOpenMetrics(&sb, snapshot)
+	}
+	sb.WriteString("# EOF\n")
 	return sb.String()
 }
 
-func getMetricName(metric Metric) string {
-	switch m := metric.(type) {
-	case *Counter:
-		return m.name
-	case *Gauge:
-		return m.name
-	case *Histogram:
-		return m.name
-	case *Summary:
-		return m.name
-	case *Info:
-		return m.name
-	case *Stateset:
-		return m.name
-	case *Untyped:
-		return m.name
-	default:
-		return "unknown_metric"
+func (r *MetricRegistry) ExportAllProtobuf() ([]byte, error) {
+	snapshots := r.Snapshot()
+	families := make([]*dto.MetricFamily, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		families = append(families, protobufFamily(snapshot))
+	}
+	return encodeDelimited(families)
+}
+
+func (r *MetricRegistry) Snapshot() []MetricSnapshot {
+	r.expireStale()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	snapshots := make([]MetricSnapshot, 0, len(names))
+	for _, name := range names {
+		metricsList := r.metrics[name]
+		if len(metricsList) == 0 {
+			continue
+		}
+		snapshot := metricsList[0].Snapshot()
+		for _, metric := range metricsList[1:] {
+			snapshot.Samples = append(snapshot.Samples, metric.Snapshot().Samples...)
+		}
+		if len(snapshot.Samples) == 0 {
+			continue
+		}
+		sort.SliceStable(snapshot.Samples, func(i, j int) bool {
+			return compareLabelPairs(snapshot.Samples[i].Labels, snapshot.Samples[j].Labels) < 0
+		})
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
+
+func (r *MetricRegistry) getOrCreateCounter(name, help string, labels map[string]string) (*Counter, error) {
+	key := seriesKey(name, formatLabels(labels))
+	r.mu.RLock()
+	counter, ok := r.series[key].(*Counter)
+	r.mu.RUnlock()
+	if ok {
+		return counter, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if counter, ok := r.series[key].(*Counter); ok {
+		return counter, nil
+	}
+	if !r.guard.allow(name, key, len(r.metrics[name])) {
+		labels = overflowLabels(labels)
+		key = seriesKey(name, formatLabels(labels))
+		if counter, ok := r.series[key].(*Counter); ok {
+			return counter, nil
+		}
+	}
+	counter = NewCounter(name, help, labels)
+	if err := r.register(counter); err != nil {
+		return nil, err
+	}
+	return counter, nil
+}
+
+func (r *MetricRegistry) getOrCreateGauge(name, help string, labels map[string]string) (*Gauge, error) {
+	key := seriesKey(name, formatLabels(labels))
+	r.mu.RLock()
+	gauge, ok := r.series[key].(*Gauge)
+	r.mu.RUnlock()
+	if ok {
+		return gauge, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if gauge, ok := r.series[key].(*Gauge); ok {
+		return gauge, nil
+	}
+	if !r.guard.allow(name, key, len(r.metrics[name])) {
+		labels = overflowLabels(labels)
+		key = seriesKey(name, formatLabels(labels))
+		if gauge, ok := r.series[key].(*Gauge); ok {
+			return gauge, nil
+		}
 	}
+	gauge = NewGauge(name, help, labels)
+	if err := r.register(gauge); err != nil {
+		return nil, err
+	}
+	return gauge, nil
 }
 
 func (r *MetricRegistry) GetCounter(name string) *Counter {
@@ -140,8 +414,8 @@ func (r *MetricRegistry) GetStateset(name string) *Stateset {
 		return nil
 	}
 	for _, metric := range metricsList {
-		if stateset, ok := metric.(*Stateset); ok {
-			return stateset
+		if provider, ok := metric.(statesetProvider); ok {
+			return provider.stateset()
 		}
 	}
 	return nil
@@ -161,3 +435,63 @@ func (r *MetricRegistry) GetUntyped(name string) *Untyped {
 	}
 	return nil
 }
+
+func (r *MetricRegistry) GetCounterVec(name string) *CounterVec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	metricsList, exists := r.metrics[name]
+	if !exists {
+		return nil
+	}
+	for _, metric := range metricsList {
+		if counterVec, ok := metric.(*CounterVec); ok {
+			return counterVec
+		}
+	}
+	return nil
+}
+
+func (r *MetricRegistry) GetGaugeVec(name string) *GaugeVec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	metricsList, exists := r.metrics[name]
+	if !exists {
+		return nil
+	}
+	for _, metric := range metricsList {
+		if gaugeVec, ok := metric.(*GaugeVec); ok {
+			return gaugeVec
+		}
+	}
+	return nil
+}
+
+func (r *MetricRegistry) GetHistogramVec(name string) *HistogramVec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	metricsList, exists := r.metrics[name]
+	if !exists {
+		return nil
+	}
+	for _, metric := range metricsList {
+		if histogramVec, ok := metric.(*HistogramVec); ok {
+			return histogramVec
+		}
+	}
+	return nil
+}
+
+func (r *MetricRegistry) GetSummaryVec(name string) *SummaryVec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	metricsList, exists := r.metrics[name]
+	if !exists {
+		return nil
+	}
+	for _, metric := range metricsList {
+		if summaryVec, ok := metric.(*SummaryVec); ok {
+			return summaryVec
+		}
+	}
+	return nil
+}
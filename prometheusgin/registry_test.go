@@ -0,0 +1,59 @@
+package prometheusgin
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeriesTTLExpiresStaleVecChildren(t *testing.T) {
+	reg := NewMetricRegistry(WithSeriesTTL(time.Minute))
+	vec := NewCounterVec("test_total", "Test counter.", []string{"path"})
+	plain := NewGauge("test_plain", "Test gauge.", nil)
+	reg.MustRegister(vec, plain)
+
+	vec.WithLabelValues("/stale").Inc()
+	vec.WithLabelValues("/fresh").Inc()
+	plain.Set(1)
+	stale := time.Now().Add(-time.Hour)
+	vec.WithLabelValues("/stale").updated.Store(stale.UnixNano())
+	plain.updated.Store(stale.UnixNano())
+
+	reg.Snapshot()
+	samples := vec.Snapshot().Samples
+	if len(samples) != 1 || samples[0].Labels[0].Value != "/fresh" {
+		t.Fatalf("got samples %+v, want only the /fresh child", samples)
+	}
+	if reg.Lookup("test_plain", nil) == nil {
+		t.Fatal("directly registered gauge was expired")
+	}
+}
+
+func TestSeriesTTLMeasuredFromLastUpdate(t *testing.T) {
+	reg := NewMetricRegistry(WithSeriesTTL(50 * time.Millisecond))
+	vec := NewGaugeVec("test_gauge", "Test gauge.", []string{"path"})
+	reg.MustRegister(vec)
+
+	vec.WithLabelValues("/a").Set(1)
+	time.Sleep(100 * time.Millisecond)
+	reg.Snapshot()
+	if n := len(vec.Snapshot().Samples); n != 0 {
+		t.Fatalf("got %d samples, want the series expired on the first scrape past its TTL", n)
+	}
+}
+
+func TestSeriesTTLKeepsInFlightActiveRequests(t *testing.T) {
+	reg := NewMetricRegistry(WithSeriesTTL(time.Minute))
+	m := NewMiddleware(reg, WithUpdatePolicy(UpdateSynchronous))
+	labels := map[string]string{LabelMethod: "GET", LabelPath: "/slow"}
+
+	m.send(MetricUpdate{Type: GaugeIncrement, Labels: labels, Value: 1, MetricPtr: m.active})
+	gauge, _ := m.active.GetMetricWith(labels)
+	gauge.updated.Store(time.Now().Add(-time.Hour).UnixNano())
+	reg.Snapshot()
+	m.send(MetricUpdate{Type: GaugeDecrement, Labels: labels, Value: -1, MetricPtr: m.active})
+
+	gauge, _ = m.active.GetMetricWith(labels)
+	if v := gauge.value(); v != 0 {
+		t.Fatalf("active_requests = %v after the request finished, want 0", v)
+	}
+}
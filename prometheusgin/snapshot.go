@@ -0,0 +1,103 @@
+// prometheusgin/snapshot.go
+
+package prometheusgin
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+type FamilyType string
+
+const (
+	TypeCounter   FamilyType = "counter"
+	TypeGauge     FamilyType = "gauge"
+	TypeHistogram FamilyType = "histogram"
+	TypeSummary   FamilyType = "summary"
+	TypeInfo      FamilyType = "info"
+	TypeStateset  FamilyType = "stateset"
+	TypeUntyped   FamilyType = "untyped"
+)
+
+type MetricSnapshot struct {
+	Name    string
+	Help    string
+	Type    FamilyType
+	Unit    string
+	Samples []Sample
+}
+
+type Sample struct {
+	Labels    []LabelPair
+	Value     float64
+	Timestamp time.Time
+	Created   time.Time
+	Count     uint64
+	Sum       float64
+	Buckets   []Bucket
+	Quantiles []Quantile
+	Native    *NativeHistogram
+	Exemplar  *Exemplar
+}
+
+type LabelPair struct {
+	Name  string
+	Value string
+}
+
+type Bucket struct {
+	UpperBound      float64
+	CumulativeCount uint64
+	Exemplar        *Exemplar
+}
+
+type Exemplar struct {
+	Labels    []LabelPair
+	Value     float64
+	Timestamp time.Time
+}
+
+type NativeHistogram struct {
+	Schema        int32
+	ZeroThreshold float64
+	ZeroCount     uint64
+	Positive      []NativeBucket
+	Negative      []NativeBucket
+}
+
+type NativeBucket struct {
+	Index int32
+	Count uint64
+}
+
+type Quantile struct {
+	Quantile float64
+	Value    float64
+}
+
+func labelPairs(labels map[string]string, extra ...string) []LabelPair {
+	pairs := make([]LabelPair, 0, len(labels)+len(extra)/2)
+	for k, v := range labels {
+		pairs = append(pairs, LabelPair{Name: k, Value: v})
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, LabelPair{Name: extra[i], Value: extra[i+1]})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Name < pairs[j].Name
+	})
+	return pairs
+}
+
+func compareLabelPairs(a, b []LabelPair) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := strings.Compare(a[i].Name, b[i].Name); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a[i].Value, b[i].Value); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
@@ -2,46 +2,125 @@ package prometheusgin
 
 import (
 	"fmt"
-	"strings"
 	"sync"
 )
 
+type statesetProvider interface {
+	stateset() *Stateset
+}
+
 type Stateset struct {
 	name        string
 	help        string
-	state       string
+	states      []string
+	active      map[string]bool
 	labels      map[string]string
 	mu          sync.Mutex
 	labelString string
 }
 
-func NewStateset(name, help string, state string, labels map[string]string) *Stateset {
+func NewStateset(name, help string, states []string, labels map[string]string) *Stateset {
 	labelStr := formatLabels(labels)
-	return &Stateset{
+	s := &Stateset{
 		name:        name,
 		help:        help,
-		state:       state,
+		states:      append([]string{}, states...),
+		active:      make(map[string]bool, len(states)),
 		labels:      labels,
 		labelString: labelStr,
 	}
+	for _, state := range states {
+		s.active[state] = false
+	}
+	if len(states) > 0 {
+		s.active[states[0]] = true
+	}
+	return s
 }
 
-func (s *Stateset) SetState(v string) {
+func (s *Stateset) stateset() *Stateset {
+	return s
+}
+
+func (s *Stateset) SetState(v string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.state = v
+	if _, ok := s.active[v]; !ok {
+		return fmt.Errorf("stateset %q: unknown state %q", s.name, v)
+	}
+	for state := range s.active {
+		s.active[state] = state == v
+	}
+	return nil
+}
+
+func (s *Stateset) State() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, state := range s.states {
+		if s.active[state] {
+			return state
+		}
+	}
+	return ""
 }
 
 func (s *Stateset) Export() string {
+	return exportText(s)
+}
+
+func (s *Stateset) Snapshot() MetricSnapshot {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("# HELP %s %s\n", s.name, s.help))
-	sb.WriteString(fmt.Sprintf("# TYPE %s stateset\n", s.name))
-	if s.labelString != "" {
-		sb.WriteString(fmt.Sprintf("%s{%s} \"%s\"\n", s.name, s.labelString, s.state))
-	} else {
-		sb.WriteString(fmt.Sprintf("%s \"%s\"\n", s.name, s.state))
-	}
-	return sb.String()
+	samples := make([]Sample, 0, len(s.states))
+	for _, state := range s.states {
+		var value float64
+		if s.active[state] {
+			value = 1
+		}
+		samples = append(samples, Sample{
+			Labels: labelPairs(s.labels, s.name, state),
+			Value:  value,
+		})
+	}
+	return MetricSnapshot{
+		Name:    s.name,
+		Help:    s.help,
+		Type:    TypeStateset,
+		Samples: samples,
+	}
+}
+
+func validateStates(name string, states []string) error {
+	if len(states) == 0 {
+		return fmt.Errorf("stateset %q: no states declared", name)
+	}
+	seen := make(map[string]bool, len(states))
+	for _, state := range states {
+		if seen[state] {
+			return fmt.Errorf("stateset %q: duplicate state %q", name, state)
+		}
+		seen[state] = true
+	}
+	return nil
+}
+
+type EnumStateset[T ~string] struct {
+	*Stateset
+}
+
+func NewEnumStateset[T ~string](name, help string, states []T, labels map[string]string) *EnumStateset[T] {
+	names := make([]string, 0, len(states))
+	for _, state := range states {
+		names = append(names, string(state))
+	}
+	return &EnumStateset[T]{Stateset: NewStateset(name, help, names, labels)}
+}
+
+func (s *EnumStateset[T]) SetState(v T) error {
+	return s.Stateset.SetState(string(v))
+}
+
+func (s *EnumStateset[T]) State() T {
+	return T(s.Stateset.State())
 }
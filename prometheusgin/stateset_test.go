@@ -0,0 +1,45 @@
+package prometheusgin
+
+import "testing"
+
+type testState string
+
+func TestRegisterStatesetReturnsRegisteredEnumStateset(t *testing.T) {
+	pg := NewPrometheusGin()
+	enum := NewEnumStateset("test_state", "Test state.", []testState{"up", "down"}, nil)
+	pg.Registry().MustRegister(enum)
+
+	got := pg.RegisterStateset("test_state", "Test state.", []string{"up", "down"}, nil)
+	if got != enum.Stateset {
+		t.Fatalf("RegisterStateset returned %p, want the registered enum stateset %p", got, enum.Stateset)
+	}
+	if err := got.SetState("down"); err != nil {
+		t.Fatal(err)
+	}
+	if state := enum.State(); state != "down" {
+		t.Fatalf("enum state = %q, want %q", state, "down")
+	}
+}
+
+func TestRegisterStatesetRejectsDifferentStates(t *testing.T) {
+	reg := NewMetricRegistry()
+	reg.MustRegister(NewStateset("test_state", "Test state.", []string{"up", "down"}, nil))
+
+	if err := reg.Register(NewStateset("test_state", "Test state.", []string{"up", "degraded"}, nil)); err == nil {
+		t.Fatal("registering a stateset with different states succeeded, want a conflict error")
+	}
+	if err := reg.Register(NewStateset("test_state", "Test state.", []string{"down", "up"}, nil)); err == nil {
+		t.Fatal("re-registering the same stateset succeeded, want an AlreadyRegisteredError")
+	} else if _, ok := err.(AlreadyRegisteredError); !ok {
+		t.Fatalf("re-registering the same states = %v, want an AlreadyRegisteredError", err)
+	}
+
+	pg := NewPrometheusGin()
+	pg.Registry().MustRegister(NewStateset("test_state", "Test state.", []string{"up", "down"}, nil))
+	defer func() {
+		if recover() == nil {
+			t.Fatal("RegisterStateset with different states did not panic")
+		}
+	}()
+	pg.RegisterStateset("test_state", "Test state.", []string{"up", "degraded"}, nil)
+}
@@ -1,81 +1,211 @@
 package prometheusgin
 
 import (
-	"fmt"
+	"math"
 	"sort"
-	"strings"
 	"sync"
+	"time"
 )
 
+const (
+	DefaultMaxAge     = 10 * time.Minute
+	DefaultAgeBuckets = 5
+)
+
+type SummaryOption func(*Summary)
+
+func WithSummaryObjectives(objectives map[float64]float64) SummaryOption {
+	return func(s *Summary) {
+		for q, epsilon := range objectives {
+			s.objectives[q] = epsilon
+		}
+	}
+}
+
+func WithSummaryMaxAge(maxAge time.Duration) SummaryOption {
+	return func(s *Summary) {
+		if maxAge > 0 {
+			s.maxAge = maxAge
+		}
+	}
+}
+
+func WithSummaryAgeBuckets(ageBuckets int) SummaryOption {
+	return func(s *Summary) {
+		if ageBuckets > 0 {
+			s.ageBuckets = ageBuckets
+		}
+	}
+}
+
 type Summary struct {
-	name         string
-	help         string
-	quantiles    []float64
-	count        int
-	sum          float64
-	observations []float64
-	labels       map[string]string
-	mu           sync.Mutex
-	labelString  string
-}
-
-func NewSummary(name, help string, quantiles []float64, labels map[string]string) *Summary {
+	name           string
+	help           string
+	objectives     map[float64]float64
+	targets        []quantileTarget
+	maxAge         time.Duration
+	ageBuckets     int
+	streams        []*quantileStream
+	headStreamIdx  int
+	headExpiration time.Time
+	streamDuration time.Duration
+	count          uint64
+	sum            float64
+	labels         map[string]string
+	mu             sync.Mutex
+	labelString    string
+	unit           string
+	created        time.Time
+	updated        time.Time
+}
+
+func NewSummary(name, help string, quantiles []float64, labels map[string]string, opts ...SummaryOption) *Summary {
 	labelStr := formatLabels(labels)
-	return &Summary{
-		name:         name,
-		help:         help,
-		quantiles:    quantiles,
-		labels:       labels,
-		labelString:  labelStr,
-		observations: []float64{},
+	now := time.Now()
+	s := &Summary{
+		name:        name,
+		help:        help,
+		objectives:  make(map[float64]float64, len(quantiles)),
+		maxAge:      DefaultMaxAge,
+		ageBuckets:  DefaultAgeBuckets,
+		labels:      labels,
+		labelString: labelStr,
+		created:     now,
+		updated:     now,
+	}
+	for _, q := range quantiles {
+		s.objectives[q] = defaultQuantileError(q)
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for q, epsilon := range s.objectives {
+		s.targets = append(s.targets, quantileTarget{quantile: q, epsilon: epsilon})
+	}
+	sort.Slice(s.targets, func(i, j int) bool {
+		return s.targets[i].quantile < s.targets[j].quantile
+	})
+	s.streams = make([]*quantileStream, s.ageBuckets)
+	for i := range s.streams {
+		s.streams[i] = newQuantileStream(s.targets)
 	}
+	s.streamDuration = s.maxAge / time.Duration(s.ageBuckets)
+	s.headExpiration = now.Add(s.streamDuration)
+	return s
+}
+
+func defaultQuantileError(q float64) float64 {
+	return math.Max(math.Min(q, 1-q)/10, 0.001)
+}
+
+func (s *Summary) SetUnit(unit string) error {
+	if err := validateUnit(s.name, TypeSummary, unit); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unit = unit
+	return nil
 }
 
 func (s *Summary) Observe(v float64) {
+	now := time.Now()
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.rotate(now)
 	s.count++
 	s.sum += v
-	s.observations = append(s.observations, v)
+	for _, stream := range s.streams {
+		stream.insert(v)
+	}
+	s.updated = now
+}
+
+func (s *Summary) rotate(now time.Time) {
+	for !now.Before(s.headExpiration) {
+		s.streams[s.headStreamIdx].reset()
+		s.headStreamIdx = (s.headStreamIdx + 1) % len(s.streams)
+		s.headExpiration = s.headExpiration.Add(s.streamDuration)
+	}
+}
+
+func (s *Summary) updatedAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updated
 }
 
 func (s *Summary) Export() string {
+	return exportText(s)
+}
+
+func (s *Summary) Snapshot() MetricSnapshot {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("# HELP %s %s\n", s.name, s.help))
-	sb.WriteString(fmt.Sprintf("# TYPE %s summary\n", s.name))
-
-	if len(s.observations) > 0 {
-		sorted := append([]float64{}, s.observations...)
-		sort.Float64s(sorted)
-		for _, q := range s.quantiles {
-			pos := q * float64(len(sorted)+1)
-			var quantile float64
-			if pos < 1 {
-				quantile = sorted[0]
-			} else if pos >= float64(len(sorted)) {
-				quantile = sorted[len(sorted)-1]
-			} else {
-				lower := sorted[int(pos)-1]
-				upper := sorted[int(pos)]
-				quantile = lower + (upper-lower)*(pos-float64(int(pos)))
-			}
-			if s.labelString != "" {
-				sb.WriteString(fmt.Sprintf("%s{quantile=\"%s\",%s} %s\n", s.name, formatFloat(q), s.labelString, formatFloat(quantile)))
-			} else {
-				sb.WriteString(fmt.Sprintf("%s{quantile=\"%s\"} %s\n", s.name, formatFloat(q), formatFloat(quantile)))
-			}
-		}
+	s.rotate(time.Now())
+	quantiles := make([]Quantile, 0, len(s.targets))
+	head := s.streams[s.headStreamIdx]
+	for _, t := range s.targets {
+		quantiles = append(quantiles, Quantile{Quantile: t.quantile, Value: head.query(t.quantile)})
+	}
+	return MetricSnapshot{
+		Name: s.name,
+		Help: s.help,
+		Type: TypeSummary,
+		Unit: s.unit,
+		Samples: []Sample{{
+			Labels:    labelPairs(s.labels),
+			Created:   s.created,
+			Count:     s.count,
+			Sum:       s.sum,
+			Quantiles: quantiles,
+		}},
 	}
+}
 
-	if s.labelString != "" {
-		sb.WriteString(fmt.Sprintf("%s_sum{%s} %s\n", s.name, s.labelString, formatFloat(s.sum)))
-		sb.WriteString(fmt.Sprintf("%s_count{%s} %d\n", s.name, s.labelString, s.count))
-	} else {
-		sb.WriteString(fmt.Sprintf("%s_sum %s\n", s.name, formatFloat(s.sum)))
-		sb.WriteString(fmt.Sprintf("%s_count %d\n", s.name, s.count))
+type SummaryVec struct {
+	*metricVec
+	targets []quantileTarget
+}
+
+func NewSummaryVec(name, help string, quantiles []float64, labelNames []string, opts ...SummaryOption) *SummaryVec {
+	prototype := NewSummary(name, help, quantiles, nil, opts...)
+	return &SummaryVec{
+		metricVec: newMetricVec(name, help, TypeSummary, labelNames, func(labels map[string]string) Metric {
+			return NewSummary(name, help, quantiles, labels, opts...)
+		}),
+		targets: prototype.targets,
 	}
+}
 
-	return sb.String()
+func (v *SummaryVec) GetMetricWithLabelValues(values ...string) (*Summary, error) {
+	metric, err := v.getMetricWithLabelValues(values)
+	if err != nil {
+		return nil, err
+	}
+	return metric.(*Summary), nil
+}
+
+func (v *SummaryVec) GetMetricWith(labels map[string]string) (*Summary, error) {
+	metric, err := v.getMetricWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return metric.(*Summary), nil
+}
+
+func (v *SummaryVec) WithLabelValues(values ...string) *Summary {
+	summary, err := v.GetMetricWithLabelValues(values...)
+	if err != nil {
+		panic(err)
+	}
+	return summary
+}
+
+func (v *SummaryVec) With(labels map[string]string) *Summary {
+	summary, err := v.GetMetricWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return summary
 }
@@ -0,0 +1,117 @@
+package prometheusgin
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestQuantileStreamAccuracy(t *testing.T) {
+	targets := []quantileTarget{{0.5, 0.05}, {0.9, 0.01}, {0.99, 0.001}}
+	stream := newQuantileStream(targets)
+
+	const n = 100000
+	values := rand.New(rand.NewSource(1)).Perm(n)
+	for _, v := range values {
+		stream.insert(float64(v))
+	}
+	sort.Ints(values)
+
+	for _, target := range targets {
+		got := stream.query(target.quantile)
+		rank := sort.SearchInts(values, int(got))
+		lo := int(math.Floor((target.quantile - target.epsilon) * n))
+		hi := int(math.Ceil((target.quantile + target.epsilon) * n))
+		if rank < lo || rank > hi {
+			t.Errorf("quantile %v = %v (rank %d), want rank in [%d, %d]", target.quantile, got, rank, lo, hi)
+		}
+	}
+	if len(stream.samples) > n/100 {
+		t.Errorf("stream kept %d samples for %d observations", len(stream.samples), n)
+	}
+}
+
+func TestSummaryQuantilesOnAscendingInput(t *testing.T) {
+	objectives := map[float64]float64{0.01: 0.001, 0.5: 0.05, 0.99: 0.001}
+	s := NewSummary("test_seconds", "Test summary.", nil, nil, WithSummaryObjectives(objectives))
+
+	const n = 10000
+	for i := 1; i <= n; i++ {
+		s.Observe(float64(i))
+	}
+
+	for _, quantile := range s.Snapshot().Samples[0].Quantiles {
+		epsilon := objectives[quantile.Quantile]
+		lo := math.Floor((quantile.Quantile - epsilon) * n)
+		hi := math.Ceil((quantile.Quantile + epsilon) * n)
+		if quantile.Value < lo || quantile.Value > hi {
+			t.Errorf("quantile %v = %v, want it in [%v, %v]", quantile.Quantile, quantile.Value, lo, hi)
+		}
+	}
+}
+
+func TestQuantileStreamReset(t *testing.T) {
+	stream := newQuantileStream([]quantileTarget{{0.5, 0.05}})
+	for i := 0; i < 1000; i++ {
+		stream.insert(float64(i))
+	}
+	stream.reset()
+	if got := stream.query(0.5); !math.IsNaN(got) {
+		t.Fatalf("query after reset = %v, want NaN", got)
+	}
+}
+
+func TestSummaryAgeBuckets(t *testing.T) {
+	s := NewSummary("test_seconds", "Test summary.", []float64{0.5}, nil, WithSummaryMaxAge(time.Minute), WithSummaryAgeBuckets(2))
+	for i := 1; i <= 100; i++ {
+		s.Observe(float64(i))
+	}
+
+	s.mu.Lock()
+	s.rotate(s.headExpiration)
+	s.mu.Unlock()
+	if got := s.Snapshot().Samples[0].Quantiles[0].Value; math.IsNaN(got) {
+		t.Fatal("median is NaN after rotating out the oldest bucket, want observations to remain")
+	}
+
+	s.mu.Lock()
+	s.rotate(s.headExpiration)
+	s.mu.Unlock()
+	sample := s.Snapshot().Samples[0]
+	if got := sample.Quantiles[0].Value; !math.IsNaN(got) {
+		t.Fatalf("median = %v after max age, want NaN", got)
+	}
+	if sample.Count != 100 || sample.Sum != 5050 {
+		t.Fatalf("count = %d, sum = %v, want 100 and 5050", sample.Count, sample.Sum)
+	}
+}
+
+func TestRegisterRejectsInvalidObjectives(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric Metric
+	}{
+		{"quantile above one", NewSummary("test_seconds", "Test summary.", []float64{1.5}, nil)},
+		{"negative quantile", NewSummary("test_seconds", "Test summary.", []float64{-0.1}, nil)},
+		{"quantile of zero", NewSummary("test_seconds", "Test summary.", []float64{0}, nil)},
+		{"quantile of one", NewSummary("test_seconds", "Test summary.", []float64{1}, nil)},
+		{"NaN quantile", NewSummary("test_seconds", "Test summary.", []float64{math.NaN()}, nil)},
+		{"error of one", NewSummary("test_seconds", "Test summary.", nil, nil, WithSummaryObjectives(map[float64]float64{0.5: 1}))},
+		{"negative error", NewSummary("test_seconds", "Test summary.", nil, nil, WithSummaryObjectives(map[float64]float64{0.5: -0.01}))},
+		{"vec quantile above one", NewSummaryVec("test_seconds", "Test summary.", []float64{2}, []string{"method"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := NewMetricRegistry().Register(tt.metric); err == nil {
+				t.Fatal("Register succeeded, want an error")
+			}
+		})
+	}
+
+	valid := NewSummary("test_seconds", "Test summary.", []float64{0.01, 0.5, 0.99}, nil, WithSummaryObjectives(map[float64]float64{0.9: 0}))
+	if err := NewMetricRegistry().Register(valid); err != nil {
+		t.Fatalf("Register(valid) = %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+// prometheusgin/text.go
+
+package prometheusgin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func textFamilyName(snapshot MetricSnapshot) string {
+	if snapshot.Type == TypeInfo {
+		return openMetricsFamilyName(snapshot.Name, TypeInfo) + "_info"
+	}
+	return snapshot.Name
+}
+
+func textType(metricType FamilyType) FamilyType {
+	switch metricType {
+	case TypeInfo, TypeStateset:
+		return TypeGauge
+	default:
+		return metricType
+	}
+}
+
+func writeText(sb *strings.Builder, snapshot MetricSnapshot) {
+	name := textFamilyName(snapshot)
+	sb.WriteString(fmt.Sprintf("# HELP %s %s\n", name, escapeHelp(snapshot.Help)))
+	sb.WriteString(fmt.Sprintf("# TYPE %s %s\n", name, textType(snapshot.Type)))
+	for _, sample := range snapshot.Samples {
+		timestamp := textTimestamp(sample.Timestamp)
+		switch snapshot.Type {
+		case TypeHistogram:
+			for _, b := range sample.Buckets {
+				writeSample(sb, name+"_bucket", formatLabelPairs(sample.Labels, "le", formatFloat(b.UpperBound)), strconv.FormatUint(b.CumulativeCount, 10), timestamp)
+			}
+			writeSample(sb, name+"_bucket", formatLabelPairs(sample.Labels, "le", "+Inf"), strconv.FormatUint(sample.Count, 10), timestamp)
+			writeSample(sb, name+"_sum", formatLabelPairs(sample.Labels), formatFloat(sample.Sum), timestamp)
+			writeSample(sb, name+"_count", formatLabelPairs(sample.Labels), strconv.FormatUint(sample.Count, 10), timestamp)
+		case TypeSummary:
+			for _, q := range sample.Quantiles {
+				writeSample(sb, name, formatLabelPairs(sample.Labels, "quantile", formatFloat(q.Quantile)), formatFloat(q.Value), timestamp)
+			}
+			writeSample(sb, name+"_sum", formatLabelPairs(sample.Labels), formatFloat(sample.Sum), timestamp)
+			writeSample(sb, name+"_count", formatLabelPairs(sample.Labels), strconv.FormatUint(sample.Count, 10), timestamp)
+		default:
+			writeSample(sb, name, formatLabelPairs(sample.Labels), formatFloat(sample.Value), timestamp)
+		}
+	}
+}
+
+func textTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+func writeSample(sb *strings.Builder, name, labelString, value, timestamp string) {
+	sb.WriteString(name)
+	if labelString != "" {
+		sb.WriteString("{" + labelString + "}")
+	}
+	sb.WriteString(" " + value)
+	if timestamp != "" {
+		sb.WriteString(" " + timestamp)
+	}
+	sb.WriteString("\n")
+}
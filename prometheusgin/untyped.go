@@ -1,8 +1,6 @@
 package prometheusgin
 
 import (
-	"fmt"
-	"strings"
 	"sync"
 )
 
@@ -13,6 +11,7 @@ type Untyped struct {
 	labels      map[string]string
 	mu          sync.Mutex
 	labelString string
+	unit        string
 }
 
 func NewUntyped(name, help string, labels map[string]string) *Untyped {
@@ -25,6 +24,16 @@ func NewUntyped(name, help string, labels map[string]string) *Untyped {
 	}
 }
 
+func (u *Untyped) SetUnit(unit string) error {
+	if err := validateUnit(u.name, TypeUntyped, unit); err != nil {
+		return err
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.unit = unit
+	return nil
+}
+
 func (u *Untyped) Set(v float64) {
 	u.mu.Lock()
 	defer u.mu.Unlock()
@@ -32,15 +41,20 @@ func (u *Untyped) Set(v float64) {
 }
 
 func (u *Untyped) Export() string {
+	return exportText(u)
+}
+
+func (u *Untyped) Snapshot() MetricSnapshot {
 	u.mu.Lock()
 	defer u.mu.Unlock()
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("# HELP %s %s\n", u.name, u.help))
-	sb.WriteString(fmt.Sprintf("# TYPE %s untyped\n", u.name))
-	if u.labelString != "" {
-		sb.WriteString(fmt.Sprintf("%s{%s} %s\n", u.name, u.labelString, formatFloat(u.value)))
-	} else {
-		sb.WriteString(fmt.Sprintf("%s %s\n", u.name, formatFloat(u.value)))
+	return MetricSnapshot{
+		Name: u.name,
+		Help: u.help,
+		Type: TypeUntyped,
+		Unit: u.unit,
+		Samples: []Sample{{
+			Labels: labelPairs(u.labels),
+			Value:  u.value,
+		}},
 	}
-	return sb.String()
 }
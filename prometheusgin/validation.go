@@ -0,0 +1,185 @@
+// prometheusgin/validation.go
+
+package prometheusgin
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRE  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	unitRE       = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+)
+
+type AlreadyRegisteredError struct {
+	ExistingMetric Metric
+	NewMetric      Metric
+}
+
+func (e AlreadyRegisteredError) Error() string {
+	return fmt.Sprintf("metric %q with the same label set is already registered", describeMetric(e.ExistingMetric).name)
+}
+
+type metricDesc struct {
+	name        string
+	help        string
+	metricType  FamilyType
+	labelNames  []string
+	labelString string
+	states      []string
+	isVec       bool
+	configErr   error
+}
+
+func describeMetric(metric Metric) metricDesc {
+	switch m := metric.(type) {
+	case *Counter:
+		return metricDesc{name: m.name, help: m.help, metricType: TypeCounter, labelNames: labelNamesOf(m.labels), labelString: m.labelString}
+	case *Gauge:
+		return metricDesc{name: m.name, help: m.help, metricType: TypeGauge, labelNames: labelNamesOf(m.labels), labelString: m.labelString}
+	case *Histogram:
+		return metricDesc{name: m.name, help: m.help, metricType: TypeHistogram, labelNames: labelNamesOf(m.labels), labelString: m.labelString, configErr: validateBuckets(m.name, m.buckets, m.native)}
+	case *Summary:
+		return metricDesc{name: m.name, help: m.help, metricType: TypeSummary, labelNames: labelNamesOf(m.labels), labelString: m.labelString, configErr: validateObjectives(m.name, m.targets)}
+	case *Info:
+		return metricDesc{name: m.name, help: m.help, metricType: TypeInfo, labelNames: append(labelNamesOf(m.labels), m.infoLabelNames()...), labelString: m.labelString}
+	case statesetProvider:
+		s := m.stateset()
+		return metricDesc{name: s.name, help: s.help, metricType: TypeStateset, labelNames: append(labelNamesOf(s.labels), s.name), labelString: s.labelString, states: s.states, configErr: validateStates(s.name, s.states)}
+	case *Untyped:
+		return metricDesc{name: m.name, help: m.help, metricType: TypeUntyped, labelNames: labelNamesOf(m.labels), labelString: m.labelString}
+	case *CounterVec:
+		return metricDesc{name: m.name, help: m.help, metricType: TypeCounter, labelNames: m.labelNames, isVec: true}
+	case *GaugeVec:
+		return metricDesc{name: m.name, help: m.help, metricType: TypeGauge, labelNames: m.labelNames, isVec: true}
+	case *HistogramVec:
+		return metricDesc{name: m.name, help: m.help, metricType: TypeHistogram, labelNames: m.labelNames, isVec: true, configErr: validateBuckets(m.name, m.buckets, m.native)}
+	case *SummaryVec:
+		return metricDesc{name: m.name, help: m.help, metricType: TypeSummary, labelNames: m.labelNames, isVec: true, configErr: validateObjectives(m.name, m.targets)}
+	default:
+		snapshot := metric.Snapshot()
+		desc := metricDesc{name: snapshot.Name, help: snapshot.Help, metricType: snapshot.Type, isVec: true}
+		if len(snapshot.Samples) > 0 {
+			for _, label := range snapshot.Samples[0].Labels {
+				desc.labelNames = append(desc.labelNames, label.Name)
+			}
+		}
+		return desc
+	}
+}
+
+func labelNamesOf(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d metricDesc) validate() error {
+	if !metricNameRE.MatchString(d.name) {
+		return fmt.Errorf("invalid metric name %q", d.name)
+	}
+	seen := make(map[string]bool, len(d.labelNames))
+	for _, label := range d.labelNames {
+		if !labelNameRE.MatchString(label) {
+			return fmt.Errorf("metric %q: invalid label name %q", d.name, label)
+		}
+		if strings.HasPrefix(label, "__") {
+			return fmt.Errorf("metric %q: label name %q is reserved", d.name, label)
+		}
+		if seen[label] {
+			return fmt.Errorf("metric %q: duplicate label name %q", d.name, label)
+		}
+		seen[label] = true
+	}
+	if d.metricType == TypeHistogram && seen["le"] {
+		return fmt.Errorf("histogram %q: label name \"le\" is reserved", d.name)
+	}
+	if d.metricType == TypeSummary && seen["quantile"] {
+		return fmt.Errorf("summary %q: label name \"quantile\" is reserved", d.name)
+	}
+	return d.configErr
+}
+
+func validateBuckets(name string, buckets []float64, native bool) error {
+	if len(buckets) == 0 && !native {
+		return fmt.Errorf("histogram %q: no buckets defined", name)
+	}
+	for i, b := range buckets {
+		if math.IsNaN(b) {
+			return fmt.Errorf("histogram %q: bucket bound is NaN", name)
+		}
+		if i == 0 {
+			continue
+		}
+		if b == buckets[i-1] {
+			return fmt.Errorf("histogram %q: duplicate bucket bound %v", name, b)
+		}
+		if b < buckets[i-1] {
+			return fmt.Errorf("histogram %q: bucket bounds must be increasing, got %v after %v", name, b, buckets[i-1])
+		}
+	}
+	return nil
+}
+
+func validateObjectives(name string, targets []quantileTarget) error {
+	for _, t := range targets {
+		if !(t.quantile > 0 && t.quantile < 1) {
+			return fmt.Errorf("summary %q: quantile %v must be in (0, 1)", name, t.quantile)
+		}
+		if !(t.epsilon >= 0 && t.epsilon < 1) {
+			return fmt.Errorf("summary %q: error %v for quantile %v must be in [0, 1)", name, t.epsilon, t.quantile)
+		}
+	}
+	return nil
+}
+
+func validateUnit(name string, metricType FamilyType, unit string) error {
+	if unit == "" {
+		return nil
+	}
+	if metricType == TypeInfo || metricType == TypeStateset {
+		return fmt.Errorf("%s %q: unit is not allowed", metricType, name)
+	}
+	if !unitRE.MatchString(unit) {
+		return fmt.Errorf("metric %q: invalid unit %q", name, unit)
+	}
+	if !strings.HasSuffix(openMetricsFamilyName(name, metricType), "_"+unit) {
+		return fmt.Errorf("metric %q: name must end in \"_%s\" to carry unit %q", name, unit, unit)
+	}
+	return nil
+}
+
+func (d metricDesc) sameLabelNames(other metricDesc) bool {
+	a, b := d.labelNames, other.labelNames
+	if !d.isVec || !other.isVec {
+		a = append([]string{}, a...)
+		b = append([]string{}, b...)
+		sort.Strings(a)
+		sort.Strings(b)
+	}
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
+
+func (d metricDesc) sameStates(other metricDesc) bool {
+	if len(d.states) != len(other.states) {
+		return false
+	}
+	a := append([]string{}, d.states...)
+	b := append([]string{}, other.states...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,204 @@
+// prometheusgin/vec.go
+
+package prometheusgin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type metricVec struct {
+	name       string
+	help       string
+	metricType FamilyType
+	labelNames []string
+	unit       string
+	children   map[string]Metric
+	guard      *cardinalityGuard
+	noExpiry   bool
+	mu         sync.RWMutex
+	newMetric  func(labels map[string]string) Metric
+}
+
+func newMetricVec(name, help string, metricType FamilyType, labelNames []string, newMetric func(labels map[string]string) Metric) *metricVec {
+	return &metricVec{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labelNames: append([]string{}, labelNames...),
+		children:   make(map[string]Metric),
+		newMetric:  newMetric,
+	}
+}
+
+func (v *metricVec) SetUnit(unit string) error {
+	if err := validateUnit(v.name, v.metricType, unit); err != nil {
+		return err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.unit = unit
+	return nil
+}
+
+func (v *metricVec) setCardinalityGuard(guard *cardinalityGuard) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.guard = guard
+}
+
+func (v *metricVec) disableExpiry() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.noExpiry = true
+}
+
+func (v *metricVec) hashLabelValues(values []string) (string, error) {
+	if len(values) != len(v.labelNames) {
+		return "", fmt.Errorf("%s: expected %d label values but got %d", v.name, len(v.labelNames), len(values))
+	}
+	return labelValuesKey(values), nil
+}
+
+func labelValuesKey(values []string) string {
+	var sb strings.Builder
+	for _, value := range values {
+		sb.WriteString(strconv.Itoa(len(value)))
+		sb.WriteByte(':')
+		sb.WriteString(value)
+	}
+	return sb.String()
+}
+
+func (v *metricVec) labelValuesFromMap(labels map[string]string) ([]string, error) {
+	if len(labels) != len(v.labelNames) {
+		return nil, fmt.Errorf("%s: expected %d labels but got %d", v.name, len(v.labelNames), len(labels))
+	}
+	values := make([]string, len(v.labelNames))
+	for i, name := range v.labelNames {
+		value, ok := labels[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: missing label %q", v.name, name)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func (v *metricVec) getMetricWithLabelValues(values []string) (Metric, error) {
+	key, err := v.hashLabelValues(values)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mu.RLock()
+	metric, exists := v.children[key]
+	v.mu.RUnlock()
+	if exists {
+		return metric, nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if metric, exists := v.children[key]; exists {
+		return metric, nil
+	}
+	if !v.guard.allow(v.name, key, len(v.children)) {
+		values = make([]string, len(v.labelNames))
+		for i := range values {
+			values[i] = OverflowLabelValue
+		}
+		key = labelValuesKey(values)
+		if metric, exists := v.children[key]; exists {
+			return metric, nil
+		}
+	}
+	labels := make(map[string]string, len(v.labelNames))
+	for i, name := range v.labelNames {
+		labels[name] = values[i]
+	}
+	metric = v.newMetric(labels)
+	v.children[key] = metric
+	return metric, nil
+}
+
+func (v *metricVec) getMetricWith(labels map[string]string) (Metric, error) {
+	values, err := v.labelValuesFromMap(labels)
+	if err != nil {
+		return nil, err
+	}
+	return v.getMetricWithLabelValues(values)
+}
+
+func (v *metricVec) DeleteLabelValues(values ...string) bool {
+	key, err := v.hashLabelValues(values)
+	if err != nil {
+		return false
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, exists := v.children[key]; !exists {
+		return false
+	}
+	delete(v.children, key)
+	return true
+}
+
+func (v *metricVec) Delete(labels map[string]string) bool {
+	values, err := v.labelValuesFromMap(labels)
+	if err != nil {
+		return false
+	}
+	return v.DeleteLabelValues(values...)
+}
+
+func (v *metricVec) Reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.children = make(map[string]Metric)
+}
+
+func (v *metricVec) expire(deadline time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.noExpiry {
+		return
+	}
+	for key, child := range v.children {
+		if tracker, ok := child.(updateTracker); ok && tracker.updatedAt().Before(deadline) {
+			delete(v.children, key)
+		}
+	}
+}
+
+func (v *metricVec) Export() string {
+	return exportText(v)
+}
+
+func (v *metricVec) Snapshot() MetricSnapshot {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	snapshot := MetricSnapshot{
+		Name: v.name,
+		Help: v.help,
+		Type: v.metricType,
+		Unit: v.unit,
+	}
+	for _, k := range v.sortedKeys() {
+		snapshot.Samples = append(snapshot.Samples, v.children[k].Snapshot().Samples...)
+	}
+	return snapshot
+}
+
+func (v *metricVec) sortedKeys() []string {
+	keys := make([]string, 0, len(v.children))
+	for k := range v.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}